@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+// defaultAggressiveCleanupWindow is how long removed records are watched for
+// when aggressiveCleanup is set.
+const defaultAggressiveCleanupWindow = 10 * time.Second
+
+// ensureRemoved checks every PollingInterval seconds, for the
+// AggressiveCleanupWindow, that none of the removed values reappeared in the
+// records of recordName, as GoDaddy briefly serves deleted records again at
+// times. The values are removed again when they do.
+func ensureRemoved(cfg godaddyDNSProviderConfig, client *godaddy.Client, domainZone string, recordName string, values []string) error {
+	window := defaultAggressiveCleanupWindow
+	if cfg.AggressiveCleanupWindow > 0 {
+		window = time.Duration(cfg.AggressiveCleanupWindow) * time.Second
+	}
+	interval := pollingInterval(cfg)
+
+	deadline := now().Add(window)
+	for now().Before(deadline) {
+		if err := sleep(cfg.context(), interval); err != nil {
+			return err
+		}
+
+		records, err := client.Records(domainZone, "TXT", recordName)
+		if err != nil {
+			return err
+		}
+		reappeared := false
+		for _, value := range values {
+			reappeared = reappeared || client.HasData(records, value)
+		}
+		if !reappeared {
+			continue
+		}
+
+		klog.Warningf("TXT record %q in zone %q reappeared after cleanup, removing it again", recordName, domainZone)
+		if err := removeValues(cfg, client, domainZone, recordName, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
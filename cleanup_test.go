@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestCleanUpAggressive(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		quote  string
+	}{
+		{"plain", `{"aggressiveCleanup": true, "aggressiveCleanupWindow": 10, "pollingInterval": 2}`, ""},
+		// The quoted value reappearing must be told apart with ignoreQuotes
+		{"quoted", `{"aggressiveCleanup": true, "aggressiveCleanupWindow": 10, "pollingInterval": 2, "ignoreQuotes": true}`, `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restore := fakeClock()
+			defer restore()
+
+			gd := newFakeGoDaddy(t)
+			path := "/v1/domains/example.com/records/TXT/_acme-challenge"
+			var writes, gets int
+			var removed []godaddy.Record
+			c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodPut, http.MethodDelete:
+					writes++
+				case http.MethodGet:
+					gets++
+					// The record reappears once right after its removal
+					if writes == 1 && gets == 2 {
+						gd.records[path] = append(gd.records[path], removed...)
+					}
+				}
+				gd.ServeHTTP(w, r)
+			}))
+			defer cleanup()
+			removed = []godaddy.Record{{Type: "TXT", Name: "_acme-challenge", Data: tt.quote + ch.Key + tt.quote}}
+			gd.records[path] = removed
+
+			if err := c.CleanUp(withConfig(t, ch, tt.config)); err != nil {
+				t.Fatalf("CleanUp failed: %v", err)
+			}
+			if writes != 2 {
+				t.Errorf("expected the reappeared record to be removed again, got %d updates", writes)
+			}
+			if values := gd.values("_acme-challenge"); len(values) != 0 {
+				t.Errorf("expected the record to be removed, got %v", values)
+			}
+			// One read before the removal, five checks and one read before the second removal
+			if gets != 7 {
+				t.Errorf("expected the records to be watched for the whole window, got %d reads", gets)
+			}
+		})
+	}
+}
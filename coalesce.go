@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+// coalesceWindow is how long the first cleanup of a record name waits for the
+// cleanups of the other challenges of the same name when coalesceCleanup is set.
+const coalesceWindow = 500 * time.Millisecond
+
+// cleanupBatch gathers the keys to remove from the records of a name.
+type cleanupBatch struct {
+	keys []string
+	// correlationID is the correlation ID of the call flushing the batch,
+	// sent along with its requests
+	correlationID string
+	done          chan struct{}
+	err           error
+}
+
+// cleanupBatcher coalesces the concurrent cleanups of the same record name,
+// e.g. of the challenges of a certificate, into a single update. The zero
+// value is ready to use.
+type cleanupBatcher struct {
+	mu      sync.Mutex
+	pending map[string]*cleanupBatch
+}
+
+// remove removes key along with the keys of the other calls for the same
+// batch. The first call waits for window so that others may join, then calls
+// flush with all the keys, and every call returns the result of flush along
+// with the correlation ID of the first call. Nothing is flushed if the context
+// of cfg is done during the window. The other calls stop waiting once their
+// own context is done or their retry deadline passed.
+func (b *cleanupBatcher) remove(cfg godaddyDNSProviderConfig, batchKey string, key string, window time.Duration, flush func(keys []string) error) (string, error) {
+	b.mu.Lock()
+	if batch, ok := b.pending[batchKey]; ok {
+		batch.keys = append(batch.keys, key)
+		b.mu.Unlock()
+		return batch.wait(cfg)
+	}
+	if b.pending == nil {
+		b.pending = map[string]*cleanupBatch{}
+	}
+	batch := &cleanupBatch{keys: []string{key}, correlationID: cfg.correlationID, done: make(chan struct{})}
+	b.pending[batchKey] = batch
+	b.mu.Unlock()
+
+	err := sleep(cfg.context(), window)
+
+	b.mu.Lock()
+	delete(b.pending, batchKey)
+	b.mu.Unlock()
+
+	if err != nil {
+		batch.err = err
+	} else {
+		batch.err = flush(batch.keys)
+	}
+	close(batch.done)
+	return batch.correlationID, batch.err
+}
+
+// wait waits for batch to be flushed by another call, unless the context of
+// cfg is done or its retry deadline passes first.
+func (batch *cleanupBatch) wait(cfg godaddyDNSProviderConfig) (string, error) {
+	var expired <-chan time.Time
+	if !cfg.retryDeadline.IsZero() {
+		timer := time.NewTimer(cfg.retryDeadline.Sub(now()))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-batch.done:
+		return batch.correlationID, batch.err
+	case <-cfg.context().Done():
+		return cfg.correlationID, cfg.context().Err()
+	case <-expired:
+		return cfg.correlationID, errors.New("gave up waiting for the coalesced cleanup past the retry deadline")
+	}
+}
+
+// removeValues removes the TXT records holding values from recordName with a
+// single update.
+func removeValues(cfg godaddyDNSProviderConfig, client *godaddy.Client, domainZone string, recordName string, values []string) error {
+	changed, err := reconcileTXT(cfg, client, domainZone, recordName, nil, values)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		logNoopCleanUp(cfg, recordName, domainZone)
+	}
+	return nil
+}
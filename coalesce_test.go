@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestCleanUpCoalesce(t *testing.T) {
+	gd := newFakeGoDaddy(t)
+	var puts int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+		}
+		gd.ServeHTTP(w, r)
+	}))
+	defer cleanup()
+
+	gd.records["/v1/domains/example.com/records/TXT/_acme-challenge"] = []godaddy.Record{
+		{Type: "TXT", Name: "_acme-challenge", Data: "key-1", TTL: 600},
+		{Type: "TXT", Name: "_acme-challenge", Data: "key-2", TTL: 600},
+		{Type: "TXT", Name: "_acme-challenge", Data: "other", TTL: 600},
+	}
+
+	// The first cleanup waits until the second one joined its batch
+	sleep = func(context.Context, time.Duration) error {
+		for {
+			c.cleanups.mu.Lock()
+			var joined int
+			for _, batch := range c.cleanups.pending {
+				joined = len(batch.keys)
+			}
+			c.cleanups.mu.Unlock()
+			if joined == 2 {
+				return nil
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	defer func() { sleep = godaddy.Sleep }()
+
+	ch = withConfig(t, ch, `{"coalesceCleanup": true}`)
+	var wg sync.WaitGroup
+	for _, key := range []string{"key-1", "key-2"} {
+		keyCh := *ch
+		keyCh.Key = key
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.CleanUp(&keyCh); err != nil {
+				t.Errorf("CleanUp failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if puts != 1 {
+		t.Errorf("expected a single update, got %d", puts)
+	}
+	if values := gd.values("_acme-challenge"); len(values) != 1 || values[0] != "other" {
+		t.Errorf("expected only the other record to remain, got %v", values)
+	}
+}
+
+func TestCleanupBatcherSeparateBatches(t *testing.T) {
+	sleep = func(context.Context, time.Duration) error { return nil }
+	defer func() { sleep = godaddy.Sleep }()
+
+	var b cleanupBatcher
+	var flushed [][]string
+	for _, batchKey := range []string{"a", "b"} {
+		_, err := b.remove(godaddyDNSProviderConfig{}, batchKey, "key-"+batchKey, time.Second, func(keys []string) error {
+			flushed = append(flushed, keys)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(flushed) != 2 || len(b.pending) != 0 {
+		t.Errorf("expected each batch to be flushed on its own, got %v", flushed)
+	}
+}
+
+func TestCleanupBatcherCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var b cleanupBatcher
+	flushed := false
+	_, err := b.remove(godaddyDNSProviderConfig{ctx: ctx}, "a", "key", time.Hour, func(keys []string) error {
+		flushed = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || flushed || len(b.pending) != 0 {
+		t.Errorf("expected the batch to be dropped once cancelled, got %v (flushed: %v)", err, flushed)
+	}
+}
+
+func TestCleanupBatcherFollowerStops(t *testing.T) {
+	var b cleanupBatcher
+	b.pending = map[string]*cleanupBatch{"a": {keys: []string{"key-1"}, correlationID: "leader", done: make(chan struct{})}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	id, err := b.remove(godaddyDNSProviderConfig{ctx: ctx, correlationID: "follower"}, "a", "key-2", time.Hour, nil)
+	if !errors.Is(err, context.Canceled) || id != "follower" {
+		t.Errorf("expected the follower to stop once cancelled, got %v and %q", err, id)
+	}
+
+	cfg := godaddyDNSProviderConfig{retryDeadline: now().Add(-time.Second)}
+	if _, err := b.remove(cfg, "a", "key-3", time.Hour, nil); err == nil {
+		t.Errorf("expected the follower to stop past its retry deadline")
+	}
+}
+
+func TestCleanUpCoalesceCorrelationID(t *testing.T) {
+	gd := newFakeGoDaddy(t)
+	var sent []string
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			sent = append(sent, r.Header.Get("X-Request-Id"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gd.ServeHTTP(w, r)
+	}))
+	defer cleanup()
+
+	gd.records["/v1/domains/example.com/records/TXT/_acme-challenge"] = []godaddy.Record{
+		{Type: "TXT", Name: "_acme-challenge", Data: "key-1", TTL: 600},
+		{Type: "TXT", Name: "_acme-challenge", Data: "key-2", TTL: 600},
+		{Type: "TXT", Name: "_acme-challenge", Data: "other", TTL: 600},
+	}
+
+	// The leader waits until the follower joined
+	sleep = func(context.Context, time.Duration) error {
+		for {
+			c.cleanups.mu.Lock()
+			var joined int
+			for _, batch := range c.cleanups.pending {
+				joined = len(batch.keys)
+			}
+			c.cleanups.mu.Unlock()
+			if joined == 2 {
+				return nil
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	defer func() { sleep = godaddy.Sleep }()
+
+	ch = withConfig(t, ch, `{"coalesceCleanup": true, "correlationHeader": "X-Request-Id"}`)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, key := range []string{"key-1", "key-2"} {
+		keyCh := *ch
+		keyCh.Key = key
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.CleanUp(&keyCh)
+		}(i)
+	}
+	wg.Wait()
+
+	if len(sent) != 1 || sent[0] == "" {
+		t.Fatalf("expected a single update carrying a correlation ID, got %q", sent)
+	}
+	for _, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "(correlation id: "+sent[0]+")") {
+			t.Errorf("expected the error to carry the correlation ID sent %s, got %v", sent[0], err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+// credentialProvider fetches the GoDaddy API key and secret referenced by the
+// configuration of a challenge. Kubernetes Secrets are read unless another
+// provider is given to newSolvers with withCredentialProvider.
+type credentialProvider interface {
+	// Fetch returns the API key and secret referenced by refs for a
+	// challenge of namespace.
+	Fetch(cfg godaddyDNSProviderConfig, refs credentialRefs, namespace string) (key string, secret string, err error)
+}
+
+// secretCredentials reads the credentials from Kubernetes Secrets.
+type secretCredentials struct {
+	solver *godaddyDNSSolver
+}
+
+func (s secretCredentials) Fetch(cfg godaddyDNSProviderConfig, refs credentialRefs, namespace string) (string, string, error) {
+	key, err := s.solver.readSecretKey(cfg, refs.APIKeyRef, namespace)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := s.solver.readSecretKey(cfg, refs.APISecretRef, namespace)
+	if err != nil {
+		return "", "", err
+	}
+	return key, secret, nil
+}
+
+// credentialSource returns the provider of the credentials, which reads
+// Kubernetes Secrets unless the solver was given another one.
+func (c *godaddyDNSSolver) credentialSource() credentialProvider {
+	if c.credentials != nil {
+		return c.credentials
+	}
+	return secretCredentials{solver: c}
+}
+
+// solverOption customizes the solvers created by newSolvers.
+type solverOption func(*godaddyDNSSolver)
+
+// withCredentialProvider makes the solvers fetch the credentials from
+// provider instead of Kubernetes Secrets.
+func withCredentialProvider(provider credentialProvider) solverOption {
+	return func(c *godaddyDNSSolver) {
+		c.credentials = provider
+	}
+}
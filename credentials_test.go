@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// fakeCredentials serves the credentials keyed by the name of the API key
+// reference.
+type fakeCredentials struct {
+	creds   map[string][2]string
+	fetched []string
+}
+
+func (f *fakeCredentials) Fetch(cfg godaddyDNSProviderConfig, refs credentialRefs, namespace string) (string, string, error) {
+	name := refs.APIKeyRef.LocalObjectReference.Name
+	f.fetched = append(f.fetched, namespace+"/"+name)
+	cred, ok := f.creds[name]
+	if !ok {
+		return "", "", errors.New("unknown credentials " + name)
+	}
+	return cred[0], cred[1], nil
+}
+
+func TestPresentCredentialProvider(t *testing.T) {
+	var auth string
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer cleanup()
+
+	provider := &fakeCredentials{creds: map[string][2]string{"godaddy": {"provider-key", "provider-secret"}}}
+	c.credentials = provider
+
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if auth != "sso-key provider-key:provider-secret" {
+		t.Errorf("expected the credentials of the provider to be used, got %q", auth)
+	}
+	if len(provider.fetched) != 1 || provider.fetched[0] != "challenge/godaddy" {
+		t.Errorf("expected the credentials of the challenge namespace to be fetched, got %v", provider.fetched)
+	}
+
+	provider.creds = nil
+	if err := c.Present(ch); err == nil {
+		t.Errorf("expected the error of the provider to be returned")
+	}
+}
+
+func TestNewSolversCredentialProvider(t *testing.T) {
+	provider := &fakeCredentials{}
+	for _, spec := range []string{"", `[{"name": "godaddy-prod"}, {"name": "godaddy-ote"}]`} {
+		solvers, err := newSolvers(godaddyDNSProviderConfig{}, spec, withCredentialProvider(provider))
+		if err != nil {
+			t.Fatalf("newSolvers failed: %v", err)
+		}
+		for _, solver := range solvers {
+			if solver.(*godaddyDNSSolver).credentialSource() != provider {
+				t.Errorf("expected solver %q to use the given credential provider", solver.Name())
+			}
+		}
+	}
+
+	solvers, _ := newSolvers(godaddyDNSProviderConfig{}, "")
+	if _, ok := solvers[0].(*godaddyDNSSolver).credentialSource().(secretCredentials); !ok {
+		t.Errorf("expected Kubernetes Secrets to be read by default")
+	}
+}
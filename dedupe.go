@@ -0,0 +1,96 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+)
+
+// recentCacheSize is the number of operations remembered by a recentCache.
+const recentCacheSize = 256
+
+// now is replaced in tests to control the passing of time.
+var now = time.Now
+
+// recentCache remembers the operations that recently succeeded so that they
+// are not processed again when retried. The least recently used entries are
+// evicted once the cache is full. Its zero value is ready to use.
+type recentCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List
+}
+
+type recentEntry struct {
+	key string
+	at  time.Time
+}
+
+// idempotencyKey identifies the record presented for a challenge.
+func idempotencyKey(ch *v1alpha1.ChallengeRequest) string {
+	sum := sha256.Sum256([]byte(ch.ResourceNamespace + "\x00" + ch.ResolvedFQDN + "\x00" + ch.Key))
+	return hex.EncodeToString(sum[:])
+}
+
+// seen reports whether key succeeded less than window ago.
+func (r *recentCache) seen(key string, window time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok {
+		return false
+	}
+	if now().Sub(e.Value.(*recentEntry).at) >= window {
+		r.order.Remove(e)
+		delete(r.entries, key)
+		return false
+	}
+	r.order.MoveToFront(e)
+	return true
+}
+
+// has reports whether key is remembered, however long ago it succeeded.
+func (r *recentCache) has(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.entries[key]
+	return ok
+}
+
+// add records that key just succeeded.
+func (r *recentCache) add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entries == nil {
+		r.entries = map[string]*list.Element{}
+	}
+	if e, ok := r.entries[key]; ok {
+		e.Value.(*recentEntry).at = now()
+		r.order.MoveToFront(e)
+		return
+	}
+	r.entries[key] = r.order.PushFront(&recentEntry{key: key, at: now()})
+	if r.order.Len() > recentCacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*recentEntry).key)
+	}
+}
+
+// remove forgets key, e.g. once the record it identifies has been cleaned up.
+func (r *recentCache) remove(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[key]; ok {
+		r.order.Remove(e)
+		delete(r.entries, key)
+	}
+}
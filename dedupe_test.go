@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPresentDedupe(t *testing.T) {
+	var puts int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+
+	ch = withConfig(t, ch, `{"dedupeWindow": 60}`)
+	for i := 0; i < 2; i++ {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	}
+	if puts != 1 {
+		t.Errorf("expected a repeated Present to be a no-op, got %d PUTs", puts)
+	}
+
+	other := *ch
+	other.Key = "another-key"
+	if err := c.Present(&other); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if puts != 2 {
+		t.Errorf("expected a Present for another key to be processed, got %d PUTs", puts)
+	}
+
+	if err := c.CleanUp(ch); err != nil {
+		t.Fatalf("CleanUp failed: %v", err)
+	}
+	puts = 0
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if puts != 1 {
+		t.Errorf("expected Present to be processed after CleanUp, got %d PUTs", puts)
+	}
+}
+
+func TestPresentWithoutDedupeWindow(t *testing.T) {
+	var puts int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	}
+	if puts != 2 {
+		t.Errorf("expected every Present to be processed, got %d PUTs", puts)
+	}
+}
+
+func TestRecentCache(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	var r recentCache
+	r.add("a")
+	if !r.seen("a", time.Minute) {
+		t.Errorf("expected a to be seen within the window")
+	}
+	current = current.Add(time.Minute)
+	if r.seen("a", time.Minute) {
+		t.Errorf("expected a to expire after the window")
+	}
+
+	for i := 0; i <= recentCacheSize; i++ {
+		r.add(string(rune('a' + i)))
+	}
+	if r.seen("a", time.Minute) {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+	if !r.seen("b", time.Minute) {
+		t.Errorf("expected b to be kept")
+	}
+	r.remove("b")
+	if r.seen("b", time.Minute) {
+		t.Errorf("expected b to be removed")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+// godaddyNameserverSuffix is the domain of the nameservers GoDaddy assigns to
+// the zones it hosts, e.g. ns01.domaincontrol.com.
+const godaddyNameserverSuffix = ".domaincontrol.com."
+
+// verifyDelegation checks that zone is delegated to the nameservers of
+// GoDaddy if VerifyDelegation is set. The records of the zones registered or
+// hosted elsewhere can be written to GoDaddy but are never served, so the
+// challenge would only time out.
+func verifyDelegation(cfg godaddyDNSProviderConfig, zone string) error {
+	if !cfg.VerifyDelegation {
+		return nil
+	}
+
+	zone = util.ToFqdn(zone)
+	in, err := dnsQuery(zone, dns.TypeNS, nameservers(cfg), true)
+	if err != nil {
+		return fmt.Errorf("could not look up the nameservers of %s: %v", zone, err)
+	}
+
+	var hosts, foreign []string
+	for _, ans := range in.Answer {
+		if ns, ok := ans.(*dns.NS); ok {
+			hosts = append(hosts, ns.Ns)
+			if !strings.HasSuffix(strings.ToLower(util.ToFqdn(ns.Ns)), godaddyNameserverSuffix) {
+				foreign = append(foreign, ns.Ns)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("could not find the nameservers of %s, check that the domain is registered and delegated", zone)
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("%s is not delegated to GoDaddy: its nameservers %s are not GoDaddy nameservers (*%s), "+
+			"check that the domain is registered with GoDaddy or delegated to the nameservers GoDaddy assigned to it",
+			zone, strings.Join(foreign, ", "), strings.TrimSuffix(godaddyNameserverSuffix, "."))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+	"github.com/miekg/dns"
+)
+
+// fakeNS makes dnsQuery answer with the NS records hosts, and returns the
+// number of queries.
+func fakeNS(t *testing.T, hosts ...string) (*int, func()) {
+	var queries int
+	dnsQuery = func(fqdn string, rtype uint16, nameservers []string, recursive bool) (*dns.Msg, error) {
+		queries++
+		if rtype != dns.TypeNS || fqdn != "example.com." {
+			t.Errorf("unexpected query of type %d for %s", rtype, fqdn)
+		}
+		in := &dns.Msg{}
+		for _, host := range hosts {
+			in.Answer = append(in.Answer, &dns.NS{Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeNS}, Ns: host})
+		}
+		return in, nil
+	}
+	return &queries, func() { dnsQuery = util.DNSQuery }
+}
+
+func TestVerifyDelegation(t *testing.T) {
+	cfg := godaddyDNSProviderConfig{VerifyDelegation: true}
+	tests := []struct {
+		hosts   []string
+		wantErr string
+	}{
+		{[]string{"ns51.domaincontrol.com.", "NS52.DomainControl.com."}, ""},
+		{[]string{"ns1.cloudflare.com.", "ns2.cloudflare.com."}, "ns1.cloudflare.com., ns2.cloudflare.com. are not GoDaddy nameservers"},
+		{[]string{"ns51.domaincontrol.com.", "ns1.cloudflare.com."}, "ns1.cloudflare.com. are not GoDaddy nameservers"},
+		{nil, "could not find the nameservers"},
+	}
+	for _, tt := range tests {
+		_, restore := fakeNS(t, tt.hosts...)
+		err := verifyDelegation(cfg, "example.com")
+		restore()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.hosts, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%v: expected an error containing %q, got %v", tt.hosts, tt.wantErr, err)
+		}
+	}
+}
+
+func TestVerifyDelegationDisabled(t *testing.T) {
+	queries, restore := fakeNS(t, "ns1.cloudflare.com.")
+	defer restore()
+
+	if err := verifyDelegation(godaddyDNSProviderConfig{}, "example.com"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if *queries != 0 {
+		t.Errorf("expected no lookup unless verifyDelegation is set, got %d", *queries)
+	}
+}
+
+func TestPresentVerifyDelegation(t *testing.T) {
+	fake := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, fake)
+	defer cleanup()
+	_, restore := fakeNS(t, "ns1.cloudflare.com.")
+	defer restore()
+
+	err := c.Present(withConfig(t, ch, `{"verifyDelegation": true}`))
+	if err == nil || !strings.Contains(err.Error(), "not delegated to GoDaddy") {
+		t.Errorf("expected Present to fail on the delegation, got %v", err)
+	}
+	if values := fake.values("_acme-challenge"); len(values) != 0 {
+		t.Errorf("expected nothing to be written, got %v", values)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// logManagedDomains logs the domains that the credentials of the environment
+// or of the default configuration can manage, for the users to check at
+// startup that the right account is configured.
+func (c *godaddyDNSSolver) logManagedDomains() {
+	cfg := c.defaults
+	if cfg.AuthAPIKey == "" {
+		cfg.AuthAPIKey, cfg.AuthAPISecret = cfg.envAPIKey, cfg.envAPISecret
+	}
+	if cfg.AuthAPIKey == "" {
+		klog.Warningf("Cannot log the domains managed by solver %s: no credentials configured by the environment", c.Name())
+		return
+	}
+
+	cfg.ctx = c.context()
+	domains, err := c.newClient(cfg).Domains()
+	if err != nil {
+		klog.Warningf("Could not list the domains managed by solver %s: %v", c.Name(), err)
+		return
+	}
+	names := make([]string, len(domains))
+	for i, d := range domains {
+		names[i] = d.Domain
+	}
+	klog.Infof("Solver %s can manage %d GoDaddy domains: %s", c.Name(), len(names), strings.Join(names, ", "))
+}
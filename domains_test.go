@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogManagedDomains(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`[{"domain": "example.com"}, {"domain": "example.org"}]`))
+	}))
+	defer srv.Close()
+
+	c := &godaddyDNSSolver{defaults: godaddyDNSProviderConfig{BaseURL: srv.URL}}
+	logs := captureLogs(c.logManagedDomains)
+	if !strings.Contains(logs, "no credentials") || auth != "" {
+		t.Errorf("expected nothing to be listed without credentials, got:\n%s", logs)
+	}
+
+	c.defaults.envAPIKey, c.defaults.envAPISecret = "the-key", "the-secret"
+	logs = captureLogs(c.logManagedDomains)
+	if !strings.Contains(logs, "can manage 2 GoDaddy domains: example.com, example.org") {
+		t.Errorf("expected the domains to be logged, got:\n%s", logs)
+	}
+	if auth != "sso-key the-key:the-secret" {
+		t.Errorf("expected the credentials of the environment to be used, got %q", auth)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"k8s.io/klog"
+)
+
+// isAuthFailure reports whether GoDaddy refused the credentials of the request
+// that failed with err. The error GoDaddy answered is looked for through the
+// wrapping errors, e.g. an unmanaged zone answered 403 in OTE is one too.
+func isAuthFailure(err error) bool {
+	return godaddy.IsUnauthorized(err) || godaddy.IsForbidden(err)
+}
+
+// inEnvironment runs op with a client of the environment of cfg. If GoDaddy
+// refuses the credentials in OTE before anything was written and
+// AutoDetectEnv is set, op is run once more against production, which is kept
+// for the rest of the operation when it accepts them. Production is never
+// retried against OTE.
+func (c *godaddyDNSSolver) inEnvironment(cfg *godaddyDNSProviderConfig, op func(client *godaddy.Client) error) error {
+	client := c.newClient(*cfg)
+	wrote := traceWrites(client)
+	err := op(client)
+	// The test key must never be presented in production
+	if !cfg.AutoDetectEnv || cfg.Production || cfg.BaseURL != "" || cfg.TestOnlyKey != "" || *wrote || !isAuthFailure(err) {
+		return err
+	}
+
+	production := *cfg
+	production.Production = true
+	if productionErr := op(c.newClient(production)); !isAuthFailure(productionErr) {
+		klog.Warning("GoDaddy refused the credentials in OTE but accepted them in production, set production: true in the issuer")
+		cfg.Production = true
+		return productionErr
+	}
+	return err
+}
+
+// traceWrites returns whether client has written anything to GoDaddy so far,
+// i.e. sent a request other than GET answered with a successful status.
+func traceWrites(client *godaddy.Client) *bool {
+	var wrote bool
+	trace := client.Trace
+	client.Trace = func(method string) func(int) {
+		var done func(int)
+		if trace != nil {
+			done = trace(method)
+		}
+		return func(statusCode int) {
+			if done != nil {
+				done(statusCode)
+			}
+			if method != http.MethodGet && statusCode >= 200 && statusCode < 300 {
+				wrote = true
+			}
+		}
+	}
+	return &wrote
+}
+
+// environmentHint completes err with a hint when GoDaddy refused the
+// credentials in OTE, as production keys are often used without setting
+// production.
+func environmentHint(cfg godaddyDNSProviderConfig, err error) error {
+	if cfg.Production || cfg.BaseURL != "" || !isAuthFailure(err) {
+		return err
+	}
+	return fmt.Errorf("%w; the request was sent to the OTE test environment, set production: true "+
+		"if the API key was issued for production", err)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+// refuse answers every request with status.
+func refuse(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+// withEnvironments serves production with prod and OTE with a server
+// rejecting every request. It returns the number of requests OTE received and
+// ch sending its requests to the environment it targets, not apiBaseURL.
+func withEnvironments(t *testing.T, c *godaddyDNSSolver, ch *v1alpha1.ChallengeRequest, prod http.Handler) (*int, *v1alpha1.ChallengeRequest, func()) {
+	return withOTE(t, c, ch, refuse(http.StatusUnauthorized), prod)
+}
+
+// withOTE is withEnvironments serving OTE with oteHandler. The requests to the
+// hosts of GoDaddy are sent to local servers by the transport of the solver.
+func withOTE(t *testing.T, c *godaddyDNSSolver, ch *v1alpha1.ChallengeRequest, oteHandler http.Handler, prod http.Handler) (*int, *v1alpha1.ChallengeRequest, func()) {
+	var oteCalls int
+	ote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oteCalls++
+		oteHandler.ServeHTTP(w, r)
+	}))
+	production := httptest.NewTLSServer(prod)
+
+	servers := map[string]string{}
+	for rawURL, srv := range map[string]*httptest.Server{godaddy.OTEURL: ote, godaddy.ProductionURL: production} {
+		u, _ := url.Parse(rawURL)
+		servers[u.Host+":443"] = srv.Listener.Addr().String()
+	}
+	// Both servers share the certificate of httptest, issued for example.com
+	transport := ote.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ServerName = "example.com"
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		local, ok := servers[addr]
+		if !ok {
+			return nil, fmt.Errorf("unexpected request to %s", addr)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, local)
+	}
+	c.transports.transports = map[transportKey]*http.Transport{{}: transport}
+
+	return &oteCalls, withConfig(t, ch, `{"apiBaseURL": ""}`), func() {
+		ote.Close()
+		production.Close()
+	}
+}
+
+func TestPresentAutoDetectEnv(t *testing.T) {
+	fake := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	oteCalls, ch, restore := withEnvironments(t, c, ch, fake)
+	defer restore()
+
+	// Production keys used against OTE get a hint
+	err := c.Present(ch)
+	if !godaddy.IsUnauthorized(err) || !strings.Contains(err.Error(), "set production: true") {
+		t.Fatalf("expected an unauthorized error hinting at production, got %v", err)
+	}
+	if len(fake.values("_acme-challenge")) != 0 {
+		t.Fatalf("expected no records to be written without autoDetectEnv")
+	}
+
+	*oteCalls = 0
+	logs := captureLogs(func() {
+		err = c.Present(withConfig(t, ch, `{"autoDetectEnv": true}`))
+	})
+	if err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if *oteCalls != 1 {
+		t.Errorf("expected OTE to be tried once, got %d requests", *oteCalls)
+	}
+	if values := fake.values("_acme-challenge"); len(values) != 1 || values[0] != ch.Key {
+		t.Errorf("expected the record to be written to production, got %v", values)
+	}
+	if !strings.Contains(logs, "set production: true") {
+		t.Errorf("expected a warning suggesting production: true, got %q", logs)
+	}
+}
+
+func TestPresentAutoDetectEnvBothRejected(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	oteCalls, ch, restore := withEnvironments(t, c, ch, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer restore()
+
+	err := c.Present(withConfig(t, ch, `{"autoDetectEnv": true}`))
+	if !godaddy.IsUnauthorized(err) || !strings.Contains(err.Error(), "OTE") {
+		t.Errorf("expected the OTE rejection to be returned, got %v", err)
+	}
+	if *oteCalls != 1 {
+		t.Errorf("expected OTE to be tried once, got %d requests", *oteCalls)
+	}
+}
+
+func TestPresentAutoDetectEnvProductionRejected(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	oteCalls, ch, restore := withEnvironments(t, c, ch, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer restore()
+
+	// Production is never retried against OTE
+	err := c.Present(withConfig(t, ch, `{"autoDetectEnv": true, "production": true}`))
+	if !godaddy.IsUnauthorized(err) || strings.Contains(err.Error(), "production: true") {
+		t.Errorf("expected the production rejection without hint, got %v", err)
+	}
+	if *oteCalls != 0 {
+		t.Errorf("expected OTE not to be tried, got %d requests", *oteCalls)
+	}
+}
+
+func TestPresentAutoDetectEnvForbidden(t *testing.T) {
+	fake := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	oteCalls, ch, restore := withOTE(t, c, ch, refuse(http.StatusForbidden), fake)
+	defer restore()
+
+	err := c.Present(ch)
+	if !godaddy.IsForbidden(err) || !strings.Contains(err.Error(), "set production: true") {
+		t.Fatalf("expected a forbidden error hinting at production, got %v", err)
+	}
+
+	*oteCalls = 0
+	if err := c.Present(withConfig(t, ch, `{"autoDetectEnv": true}`)); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if *oteCalls != 1 {
+		t.Errorf("expected OTE to be tried once, got %d requests", *oteCalls)
+	}
+	if values := fake.values("_acme-challenge"); len(values) != 1 || values[0] != ch.Key {
+		t.Errorf("expected the record to be written to production, got %v", values)
+	}
+}
+
+func TestPresentAutoDetectEnvAfterWrite(t *testing.T) {
+	fake := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	// OTE accepts the write but refuses the read-back
+	var gets int
+	_, ch, restore := withOTE(t, c, ch, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if gets++; gets > 1 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+	}), fake)
+	defer restore()
+
+	err := c.Present(withConfig(t, ch, `{"autoDetectEnv": true, "verifyWrite": true}`))
+	if !godaddy.IsForbidden(err) || !strings.Contains(err.Error(), "set production: true") {
+		t.Errorf("expected the OTE error hinting at production, got %v", err)
+	}
+	if values := fake.values("_acme-challenge"); len(values) != 0 {
+		t.Errorf("expected nothing to be written to production once OTE was written, got %v", values)
+	}
+}
+
+func TestEnvironmentHint(t *testing.T) {
+	unauthorized := &godaddy.UnauthorizedError{APIError: &godaddy.APIError{Op: "get records", StatusCode: 401}}
+	forbidden := &godaddy.ForbiddenError{APIError: &godaddy.APIError{Op: "get records", StatusCode: 403}}
+	other := &godaddy.APIError{Op: "get records", StatusCode: 500}
+	unmanaged := &unmanagedZoneError{zone: "example.com", statusCode: 403, err: forbidden}
+	unknown := &unmanagedZoneError{zone: "example.com", statusCode: 404, err: &godaddy.APIError{Op: "get records", StatusCode: 404}}
+
+	for _, test := range []struct {
+		cfg  godaddyDNSProviderConfig
+		err  error
+		hint bool
+	}{
+		{godaddyDNSProviderConfig{}, unauthorized, true},
+		{godaddyDNSProviderConfig{}, forbidden, true},
+		{godaddyDNSProviderConfig{}, other, false},
+		{godaddyDNSProviderConfig{}, unmanaged, true},
+		{godaddyDNSProviderConfig{}, unknown, false},
+		{godaddyDNSProviderConfig{Production: true}, unauthorized, false},
+		{godaddyDNSProviderConfig{BaseURL: "https://godaddy.internal"}, unauthorized, false},
+	} {
+		err := environmentHint(test.cfg, test.err)
+		if hint := strings.Contains(err.Error(), "production: true"); hint != test.hint {
+			t.Errorf("environmentHint(%+v, %v) = %v, expected a hint: %t", test.cfg, test.err, err, test.hint)
+		}
+	}
+}
+
+func TestValidateAutoDetectEnv(t *testing.T) {
+	c := &godaddyDNSSolver{}
+	cfg := godaddyDNSProviderConfig{
+		AuthAPIKey:    "the-key",
+		AuthAPISecret: "the-secret",
+		AutoDetectEnv: true,
+		BaseURL:       "https://godaddy.internal",
+	}
+	if err := c.validate(&cfg); err == nil || !strings.Contains(err.Error(), "autoDetectEnv") {
+		t.Errorf("expected autoDetectEnv to be rejected along with apiBaseURL, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"golang.org/x/net/idna"
+)
+
+// normalizeIDN returns ch with its FQDN and zone converted to their ASCII
+// (punycode) form, as expected by GoDaddy, unless DisableIDNConversion is set.
+// ch itself is left untouched.
+func normalizeIDN(cfg godaddyDNSProviderConfig, ch *v1alpha1.ChallengeRequest) (*v1alpha1.ChallengeRequest, error) {
+	if cfg.DisableIDNConversion {
+		return ch, nil
+	}
+	fqdn, err := toASCII(ch.ResolvedFQDN)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := toASCII(ch.ResolvedZone)
+	if err != nil {
+		return nil, err
+	}
+	if fqdn == ch.ResolvedFQDN && zone == ch.ResolvedZone {
+		return ch, nil
+	}
+	normalized := *ch
+	normalized.ResolvedFQDN, normalized.ResolvedZone = fqdn, zone
+	return &normalized, nil
+}
+
+// toASCII converts the labels of name holding non-ASCII characters to
+// punycode, once mapped and validated as IDNA lookups do. The ASCII labels are
+// left as is, as the IDNA rules would reject the underscore of the challenge
+// prefix.
+func toASCII(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("invalid domain name %q: not valid UTF-8", name)
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		ascii, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("invalid domain name %q: %v", name, err)
+		}
+		labels[i] = ascii
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.com.", "example.com."},
+		{"_acme-challenge.bücher.example.", "_acme-challenge.xn--bcher-kva.example."},
+		{"MÜNCHEN.de", "xn--mnchen-3ya.de"},
+		{"テスト.jp", "xn--zckzah.jp"},
+		{"日本語.jp", "xn--wgv71a119e.jp"},
+		{"ドメイン名例.jp", "xn--eckwd4c7cu47r2wf.jp"},
+		{"ｂücher.example.", "xn--bcher-kva.example."},
+	}
+	for _, tt := range tests {
+		got, err := toASCII(tt.name)
+		if err != nil {
+			t.Errorf("toASCII(%q) returned unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("toASCII(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, name := range []string{"b\xffcher.example.", "bü_cher.example.", "ü-.example."} {
+		if _, err := toASCII(name); err == nil {
+			t.Errorf("toASCII(%q) expected an error", name)
+		}
+	}
+}
+
+func TestPresentIDN(t *testing.T) {
+	var paths []string
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		return fqdn, nil
+	}
+	ch.ResolvedFQDN = "_acme-challenge.www.bücher.example."
+	ch.ResolvedZone = "bücher.example."
+
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("expected requests to GoDaddy")
+	}
+	for _, path := range paths {
+		if path != "/v1/domains/xn--bcher-kva.example/records/TXT/_acme-challenge.www" {
+			t.Errorf("expected the punycode name in the path, got %s", path)
+		}
+	}
+	if ch.ResolvedFQDN != "_acme-challenge.www.bücher.example." {
+		t.Errorf("expected the challenge to be left untouched, got %q", ch.ResolvedFQDN)
+	}
+
+	ch = withConfig(t, ch, `{"disableIDNConversion": true}`)
+	if err := c.Present(ch); err == nil || !strings.Contains(err.Error(), "invalid character") {
+		t.Errorf("expected the internationalized name to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,1013 @@
+// Package godaddy implements a client for the DNS records of the GoDaddy
+// domains API.
+// See - https://developer.godaddy.com/doc/endpoint/domains
+package godaddy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	pkgutil "github.com/jetstack/cert-manager/pkg/util"
+)
+
+const (
+	// OTEURL is the URL of the GoDaddy API test environment
+	OTEURL = "https://api.ote-godaddy.com"
+	// ProductionURL is the URL of the GoDaddy API production environment
+	ProductionURL = "https://api.godaddy.com"
+
+	// DefaultTTL is the TTL of the records GoDaddy creates by default
+	DefaultTTL = 3600
+	// DefaultTimeout is the timeout of the requests unless Timeout is set
+	DefaultTimeout = 30 * time.Second
+	// MaxBackoff bounds the delay before a retry, see Backoff
+	MaxBackoff = time.Minute
+
+	defaultConflictRetries = 3
+	defaultMaxRetries      = 3
+	defaultRetryBackoff    = 500 * time.Millisecond
+	maxRedirects           = 10
+	maxIdleConnsPerHost    = 16
+	// maxPages bounds the number of pages of records read
+	maxPages = 100
+
+	// authScheme is the scheme of the Authorization header, which GoDaddy
+	// matches case-sensitively
+	authScheme = "sso-key"
+)
+
+var (
+	// sleep is replaced in tests to avoid waiting between retries.
+	sleep = Sleep
+	// now is replaced in tests to control the retry deadline.
+	now = time.Now
+)
+
+// BaseURL returns the URL of the production or of the test (OTE) environment
+// of the GoDaddy API.
+func BaseURL(production bool) string {
+	if production {
+		return ProductionURL
+	}
+	return OTEURL
+}
+
+// Record a DNS record
+type Record struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Data     string `json:"data"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Service  string `json:"service,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// Versions of the schema of the records written.
+const (
+	// SchemaV1 sends every field of Record
+	SchemaV1 = "v1"
+	// SchemaV2 leaves out the type and the name, given by the path of the
+	// request
+	SchemaV2 = "v2"
+)
+
+// recordV2 is a Record in SchemaV2.
+type recordV2 struct {
+	Data     string `json:"data"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Service  string `json:"service,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// sanitize clears the fields that do not apply to the type of the record, as
+// GoDaddy may reject e.g. a TXT record carrying a priority or a weight.
+func (r Record) sanitize() Record {
+	switch r.Type {
+	case "SRV":
+	case "MX":
+		r.Weight, r.Port, r.Service, r.Protocol = 0, 0, "", ""
+	default:
+		r.Priority, r.Weight, r.Port, r.Service, r.Protocol = 0, 0, 0, "", ""
+	}
+	return r
+}
+
+// APIError is returned when the GoDaddy API answers with an unexpected status.
+// Code, Message and Fields are set when the body is an error object of the
+// GoDaddy API, e.g. with the code DUPLICATE_RECORD or INVALID_BODY.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Body       string
+	Code       string
+	Message    string
+	Fields     []ErrorField
+}
+
+// ErrorField is a field of the request that GoDaddy rejected.
+type ErrorField struct {
+	Path        string `json:"path"`
+	PathRelated string `json:"pathRelated,omitempty"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s; Status: %v; Body: %s", e.Op, e.StatusCode, e.Body)
+	}
+	msg := fmt.Sprintf("%s; Status: %v; Code: %s; Message: %s", e.Op, e.StatusCode, e.Code, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; Field %s: %s (%s)", f.Path, f.Message, f.Code)
+	}
+	return msg
+}
+
+// UnauthorizedError is returned when GoDaddy rejects the credentials, i.e.
+// answers 401.
+type UnauthorizedError struct {
+	*APIError
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.APIError.Error() + "; GoDaddy rejected the API key and secret, check that they are valid " +
+		"and were issued for the targeted environment (OTE or production)"
+}
+
+func (e *UnauthorizedError) Unwrap() error {
+	return e.APIError
+}
+
+// ForbiddenError is returned when GoDaddy accepts the credentials but does not
+// allow them to manage the domain, i.e. answers 403.
+type ForbiddenError struct {
+	*APIError
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.APIError.Error() + "; the API key is valid but not allowed to manage the domain, check that " +
+		"the domain belongs to the account of the key and that the account has access to the DNS API"
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.APIError
+}
+
+// newAPIError returns the error of a call answered with statusCode and body,
+// typed after the status when GoDaddy refused the credentials.
+func newAPIError(op string, statusCode int, body string) error {
+	apiErr := &APIError{Op: op, StatusCode: statusCode, Body: body}
+	var parsed errorBody
+	if json.Unmarshal([]byte(body), &parsed) == nil {
+		apiErr.Code, apiErr.Message, apiErr.Fields = parsed.Code, parsed.Message, parsed.Fields
+	}
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return &UnauthorizedError{apiErr}
+	case http.StatusForbidden:
+		return &ForbiddenError{apiErr}
+	}
+	return apiErr
+}
+
+// isRetryable reports whether a failed GoDaddy call may succeed once the
+// records have been read again, e.g. after a conflicting concurrent write.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// IsUnauthorized reports whether GoDaddy rejected the credentials of the
+// request that failed with err.
+func IsUnauthorized(err error) bool {
+	var unauthorized *UnauthorizedError
+	return errors.As(err, &unauthorized)
+}
+
+// IsForbidden reports whether GoDaddy did not allow the credentials of the
+// request that failed with err to manage the domain.
+func IsForbidden(err error) bool {
+	var forbidden *ForbiddenError
+	return errors.As(err, &forbidden)
+}
+
+// Client manages the DNS records of the domains of a GoDaddy account.
+type Client struct {
+	// BaseURL is the URL of the GoDaddy API, see BaseURL
+	BaseURL string
+	// APIKey and APISecret authenticate the requests
+	APIKey    string
+	APISecret string
+
+	// Timeout bounds each request, 30 seconds by default
+	Timeout time.Duration
+	// MaxRetries is the number of times a rate limited request or a request
+	// failing with a server error is retried
+	MaxRetries int
+	// RetryBackoff is the delay before retrying a request without Retry-After
+	// header, doubled after each attempt up to MaxBackoff
+	RetryBackoff time.Duration
+	// ConflictRetries is the number of times Reconcile retries an update
+	// rejected because of a concurrent write
+	ConflictRetries int
+	// MaxValues, if set, is the number of records of a name Reconcile may
+	// write at most
+	MaxValues int
+	// PruneOnLimit removes the records Stale reports when adding one would
+	// exceed MaxValues, instead of failing. It still fails when there are not
+	// enough of them
+	PruneOnLimit bool
+	// Stale reports whether the value of a record is known to be left over,
+	// e.g. by a challenge cleaned up since. Nothing is pruned if not set
+	Stale func(data string) bool
+	// IgnoreQuotes compares the values of the records without their
+	// surrounding quotes, which GoDaddy keeps or not depending on the input
+	IgnoreQuotes bool
+	// SortRecords sorts the records written by Reconcile by data, so that
+	// they are always sent in the same order
+	SortRecords bool
+	// MethodOverride sends PUT and DELETE requests as POST with a
+	// X-HTTP-Method-Override header
+	MethodOverride bool
+	// DisableRedirects returns redirect responses as is instead of following
+	// them
+	DisableRedirects bool
+	// Transport, if set, sends the requests instead of the default transport.
+	// Clients sharing a transport reuse its connections and TLS sessions, see
+	// NewTransport
+	Transport http.RoundTripper
+	// DisableChunked forbids chunked request bodies, for proxies requiring a
+	// Content-Length
+	DisableChunked bool
+	// BodySchema is the version of the schema of the records written, see
+	// SchemaV1 and SchemaV2. SchemaV1 is used by default
+	BodySchema string
+	// ExcludeFields are the JSON fields left out of the records written, e.g.
+	// fields not yet accepted by the targeted environment
+	ExcludeFields []string
+	// PageSize, if set, is the number of records read per request
+	PageSize int
+	// StrictDecoding rejects the records holding fields unknown to Record,
+	// to catch changes of the API early
+	StrictDecoding bool
+	// CheckResponseBody fails the writes answered with a successful status
+	// but an error object in the body
+	CheckResponseBody bool
+	// ShopperID, if set, is the customer a reseller acts on behalf of
+	ShopperID string
+	// LogResponseHeaders logs the headers of the responses listed by
+	// loggedHeaders at verbosity 2
+	LogResponseHeaders bool
+	// CorrelationHeader, if set, is the header carrying CorrelationID
+	CorrelationHeader string
+	CorrelationID     string
+	// Trace, if set, is called before each request sent to GoDaddy and the
+	// function it returns once the response is received, with its status code
+	// or 0 if the request failed
+	Trace func(method string) (done func(statusCode int))
+	// LogData, if set, replaces the values of the records in the request and
+	// response dumps logged at verbosity 4. They are redacted otherwise
+	LogData func(data string) string
+	// Retried, if set, is called each time a request or an update is retried
+	Retried func()
+	// RetryDeadline, if set, is the time after which nothing is retried
+	// anymore, the last error being returned instead
+	RetryDeadline time.Time
+	// Context, if set, cancels the requests in flight and the retries once
+	// done
+	Context context.Context
+}
+
+// checkWritable returns an error for the record types the client must never
+// modify. CAA records control which CAs may issue certificates for a zone and
+// are never needed to solve a DNS-01 challenge.
+func checkWritable(recordType string) error {
+	if strings.EqualFold(recordType, "CAA") {
+		return fmt.Errorf("refusing to modify %s records", recordType)
+	}
+	return nil
+}
+
+// recordsURI returns the path of the records of the given type and name of
+// domain, escaping each segment.
+func recordsURI(domain string, recordType string, name string) string {
+	return fmt.Sprintf("/v1/domains/%s/records/%s/%s", url.PathEscape(domain), url.PathEscape(recordType), url.PathEscape(name))
+}
+
+// Records returns the records of the given type and name of domain. When
+// PageSize is set, they are read one page at a time, up to maxPages pages.
+func (c *Client) Records(domain string, recordType string, name string) ([]Record, error) {
+	uri := recordsURI(domain, recordType, name)
+	if c.PageSize <= 0 {
+		return c.recordsPage(uri, name)
+	}
+
+	var records, previous []Record
+	for pages := 0; pages < maxPages; pages++ {
+		page, err := c.recordsPage(fmt.Sprintf("%s?offset=%d&limit=%d", uri, pages*c.PageSize, c.PageSize), name)
+		if err != nil {
+			return nil, err
+		}
+		// An API ignoring the offset would answer the same page forever
+		if pages > 0 && samePage(page, previous) {
+			return nil, fmt.Errorf("could not get records of %s: the same page was returned twice, the API may not support pageSize", name)
+		}
+		records = append(records, page...)
+		if len(page) < c.PageSize {
+			return records, nil
+		}
+		previous = page
+	}
+	return nil, fmt.Errorf("could not get records of %s: more than %d pages of %d records", name, maxPages, c.PageSize)
+}
+
+func samePage(a []Record, b []Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) recordsPage(uri string, name string) ([]Record, error) {
+	resp, err := c.do(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return nil, newAPIError(fmt.Sprintf("could not get records of %s", name), resp.StatusCode, string(bodyBytes))
+	}
+
+	var records []Record
+	decoder := json.NewDecoder(resp.Body)
+	if c.StrictDecoding {
+		decoder.DisallowUnknownFields()
+	}
+	// GoDaddy occasionally answers with an empty body when there is no record
+	if err := decoder.Decode(&records); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not decode records of %s: %v", name, err)
+	}
+	return records, nil
+}
+
+// ReplaceRecords replaces the records of the given type and name of domain.
+func (c *Client) ReplaceRecords(domain string, recordType string, name string, records []Record) error {
+	if err := checkWritable(recordType); err != nil {
+		return err
+	}
+
+	sanitized := make([]Record, len(records))
+	for i, r := range records {
+		if err := checkWritable(r.Type); err != nil {
+			return err
+		}
+		sanitized[i] = r.sanitize()
+	}
+
+	body, err := c.marshalRecords(sanitized)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(http.MethodPut, recordsURI(domain, recordType, name), body)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	// The body of a successful write, often empty, holds nothing to decode
+	op := fmt.Sprintf("could not create record %v", string(body))
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return newAPIError(op, resp.StatusCode, string(bodyBytes))
+	}
+	return c.checkResponseBody(op, resp)
+}
+
+// marshalRecords encodes records in the schema selected by BodySchema,
+// without ExcludeFields.
+func (c *Client) marshalRecords(records []Record) ([]byte, error) {
+	body, err := c.marshalSchema(records)
+	if err != nil || len(c.ExcludeFields) == 0 {
+		return body, err
+	}
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &objects); err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		for _, field := range c.ExcludeFields {
+			delete(object, field)
+		}
+	}
+	return json.Marshal(objects)
+}
+
+func (c *Client) marshalSchema(records []Record) ([]byte, error) {
+	switch c.BodySchema {
+	case "", SchemaV1:
+		return json.Marshal(records)
+	case SchemaV2:
+		v2 := make([]recordV2, len(records))
+		for i, r := range records {
+			v2[i] = recordV2{
+				Data:     r.Data,
+				Priority: r.Priority,
+				Weight:   r.Weight,
+				Port:     r.Port,
+				Service:  r.Service,
+				Protocol: r.Protocol,
+				TTL:      r.TTL,
+			}
+		}
+		return json.Marshal(v2)
+	default:
+		return nil, fmt.Errorf("unknown body schema %q", c.BodySchema)
+	}
+}
+
+// DeleteRecords deletes all the records of the given type and name of domain.
+func (c *Client) DeleteRecords(domain string, recordType string, name string) error {
+	if err := checkWritable(recordType); err != nil {
+		return err
+	}
+
+	resp, err := c.do(http.MethodDelete, recordsURI(domain, recordType, name), nil)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	op := fmt.Sprintf("could not delete records of %s", name)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return newAPIError(op, resp.StatusCode, string(bodyBytes))
+	}
+	return c.checkResponseBody(op, resp)
+}
+
+// errorBody is the error object of the GoDaddy API.
+type errorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []ErrorField `json:"fields"`
+}
+
+// checkResponseBody returns an error when CheckResponseBody is set and the
+// body of a successful write holds an error object nonetheless.
+func (c *Client) checkResponseBody(op string, resp *http.Response) error {
+	if !c.CheckResponseBody {
+		return nil
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: could not read response: %v", op, err)
+	}
+
+	var body errorBody
+	if json.Unmarshal(bodyBytes, &body) == nil && body.Code != "" {
+		return newAPIError(op, resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// ReconcileTXT adds the TXT records holding the values of add to, and
+// removes the ones holding the values of remove from, the TXT records of name,
+// see Reconcile.
+func (c *Client) ReconcileTXT(domain string, name string, add []string, remove []string, ttl int) (bool, error) {
+	records := make([]Record, len(add))
+	for i, data := range add {
+		records[i] = Record{Type: "TXT", Name: name, Data: data, TTL: ttl}
+	}
+	return c.Reconcile(domain, "TXT", name, records, remove, ttl)
+}
+
+// Reconcile adds the records of add that are missing and removes the records
+// holding the data of remove from the records of the given type and name of
+// domain, with a single update made only when something changes, or a delete
+// when no record is left. It reports whether the records were updated.
+// GoDaddy replaces every record of a name on update, so the existing records
+// are read first and written back along with the changes. When the update is
+// rejected because of a concurrent write, the records are read again and the
+// update is retried. The records read without TTL are written back with ttl,
+// or DefaultTTL if not set.
+func (c *Client) Reconcile(domain string, recordType string, name string, add []Record, remove []string, ttl int) (bool, error) {
+	retries := c.ConflictRetries
+	if retries <= 0 {
+		retries = defaultConflictRetries
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		var records []Record
+		records, err = c.Records(domain, recordType, name)
+		if err != nil {
+			return false, err
+		}
+
+		// The values to add may already be there, e.g. presented by another
+		// caller and merged with ours by GoDaddy
+		var missing []Record
+		for _, r := range add {
+			if !c.HasData(records, r.Data) && !c.HasData(missing, r.Data) {
+				missing = append(missing, r)
+			}
+		}
+		removing := false
+		for _, data := range remove {
+			removing = removing || c.HasData(records, data)
+		}
+		if len(missing) == 0 && !removing {
+			return false, nil
+		}
+
+		merged := make([]Record, 0, len(records)+len(missing))
+		for _, r := range records {
+			// Identical values are written back once, as GoDaddy would
+			// merge them anyway
+			if c.containsData(remove, r.Data) || c.HasData(merged, r.Data) {
+				continue
+			}
+			// GoDaddy may omit the TTL, which must not be reset when the
+			// record is written back
+			if r.TTL == 0 {
+				r.TTL = ttl
+			}
+			merged = append(merged, r)
+		}
+		if c.MaxValues > 0 && len(missing) > 0 && len(merged)+len(missing) > c.MaxValues {
+			// GoDaddy returns the records in no particular order, so only the
+			// values known to be left over may go
+			kept := merged
+			if c.PruneOnLimit {
+				kept = c.pruneStale(merged, len(merged)+len(missing)-c.MaxValues)
+			}
+			if len(kept)+len(missing) > c.MaxValues {
+				return false, fmt.Errorf("%s record %q of %s already holds %d values, no more than %d are allowed", recordType, name, domain, len(merged), c.MaxValues)
+			}
+			klog.Warningf("%s record %q of %s holds %d values, pruning %d stale ones", recordType, name, domain, len(merged), len(merged)-len(kept))
+			merged = kept
+		}
+		records = append(merged, missing...)
+		if c.SortRecords {
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].Data < records[j].Data
+			})
+		}
+		if len(records) == 0 {
+			// GoDaddy rejects an empty list of records
+			err = c.DeleteRecords(domain, recordType, name)
+		} else {
+			err = c.ReplaceRecords(domain, recordType, name, records)
+		}
+		if !isRetryable(err) || attempt >= retries || !c.canRetry(0) {
+			return err == nil, err
+		}
+		klog.Warningf("Conflict while updating %s record %q in zone %q, retrying", recordType, name, domain)
+		c.retried()
+	}
+	return false, err
+}
+
+// pruneStale returns records without at most count of the records whose
+// values Stale reports.
+func (c *Client) pruneStale(records []Record, count int) []Record {
+	if c.Stale == nil {
+		return records
+	}
+	kept := make([]Record, 0, len(records))
+	for _, r := range records {
+		data := r.Data
+		if c.IgnoreQuotes {
+			data = unquote(data)
+		}
+		if count > 0 && c.Stale(data) {
+			count--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+func (c *Client) containsData(values []string, data string) bool {
+	for _, v := range values {
+		if c.sameData(v, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasData reports whether one of records holds data, compared like the values
+// reconciled by the client, i.e. without their surrounding quotes when
+// IgnoreQuotes is set.
+func (c *Client) HasData(records []Record, data string) bool {
+	for _, r := range records {
+		if c.sameData(r.Data, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameData reports whether two record values are identical, once their
+// surrounding quotes are removed when IgnoreQuotes is set.
+func (c *Client) sameData(a string, b string) bool {
+	if c.IgnoreQuotes {
+		return unquote(a) == unquote(b)
+	}
+	return a == b
+}
+
+// unquote removes the double quotes surrounding data, if any.
+func unquote(data string) string {
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		return data[1 : len(data)-1]
+	}
+	return data
+}
+
+// do sends a request to the GoDaddy API. Rate limited requests and requests
+// failing with a server error are retried up to MaxRetries times.
+func (c *Client) do(method string, uri string, body []byte) (*http.Response, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := http.Client{
+		Transport:     c.transport(),
+		Timeout:       timeout,
+		CheckRedirect: c.checkRedirect,
+	}
+
+	maxRetries := c.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	// Proxies blocking PUT and DELETE let POST requests through
+	sentMethod := method
+	if c.MethodOverride && method != http.MethodGet && method != http.MethodPost {
+		sentMethod = http.MethodPost
+	}
+
+	ctx := c.context()
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, sentMethod, fmt.Sprintf("%s%s", c.BaseURL, uri), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if sentMethod != method {
+			req.Header.Set("X-HTTP-Method-Override", method)
+		}
+		if c.DisableChunked {
+			// The length of a bytes.Reader body is known, this only makes
+			// sure no transport ever falls back to chunked encoding
+			req.ContentLength = int64(len(body))
+			req.TransferEncoding = []string{"identity"}
+		}
+
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", pkgutil.CertManagerUserAgent)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s:%s", authScheme, c.APIKey, c.APISecret))
+		if c.ShopperID != "" {
+			req.Header.Set("X-Shopper-Id", c.ShopperID)
+		}
+		if c.CorrelationHeader != "" && c.CorrelationID != "" {
+			req.Header.Set(c.CorrelationHeader, c.CorrelationID)
+		}
+
+		// The request and response dumps hold neither the credentials nor the
+		// values of the records
+		if klog.V(4) {
+			klog.Infof("GoDaddy request: %s %s %s", method, uri, c.bodyForLog(body))
+		}
+
+		var done func(int)
+		if c.Trace != nil {
+			done = c.Trace(method)
+		}
+		resp, err := client.Do(req)
+		if done != nil {
+			statusCode := 0
+			if err == nil {
+				statusCode = resp.StatusCode
+			}
+			done(statusCode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.LogResponseHeaders {
+			klog.V(2).Infof("GoDaddy response headers to %s %s: %s", method, uri, headersForLog(resp.Header))
+		}
+		if klog.V(4) {
+			c.dumpResponse(method, uri, resp)
+		}
+		if !isTransient(resp.StatusCode) || attempt >= maxRetries {
+			return resp, nil
+		}
+		delay := c.retryDelay(resp, attempt)
+		if !c.canRetry(delay) {
+			klog.Warningf("GoDaddy answered %s %s with status %d, not retrying past the retry deadline", method, uri, resp.StatusCode)
+			return resp, nil
+		}
+		c.retried()
+
+		resp.Body.Close()
+		klog.Warningf("GoDaddy answered %s %s with status %d, retrying in %v", method, uri, resp.StatusCode, delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loggedHeaders are the response headers logged when LogResponseHeaders is
+// set. Only headers known to carry no credentials may be listed.
+var loggedHeaders = []string{
+	"X-Request-Id",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+	"Retry-After",
+}
+
+// headersForLog formats the values of loggedHeaders found in header.
+func headersForLog(header http.Header) string {
+	var logged []string
+	for _, name := range loggedHeaders {
+		if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			logged = append(logged, fmt.Sprintf("%s=%s", name, strings.Join(values, ",")))
+		}
+	}
+	return strings.Join(logged, " ")
+}
+
+// dumpResponse logs the status and the body of resp, leaving the body
+// readable.
+func (c *Client) dumpResponse(method string, uri string, resp *http.Response) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		klog.Infof("GoDaddy response to %s %s: %d, could not read body: %v", method, uri, resp.StatusCode, err)
+		return
+	}
+	klog.Infof("GoDaddy response to %s %s: %d %s", method, uri, resp.StatusCode, c.bodyForLog(body))
+}
+
+// bodyForLog returns body with the values of the records it holds replaced by
+// LogData, or redacted. Bodies which are not JSON are returned as is.
+func (c *Client) bodyForLog(body []byte) string {
+	var decoded interface{}
+	if len(body) == 0 || json.Unmarshal(body, &decoded) != nil {
+		return string(body)
+	}
+	c.replaceData(decoded)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(decoded); err != nil {
+		return string(body)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// replaceData replaces the data fields found in the decoded JSON value v.
+func (c *Client) replaceData(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			c.replaceData(elem)
+		}
+	case map[string]interface{}:
+		for name, field := range v {
+			data, ok := field.(string)
+			if name != "data" || !ok {
+				c.replaceData(field)
+				continue
+			}
+			if c.LogData != nil {
+				v[name] = c.LogData(data)
+			} else {
+				v[name] = "<redacted>"
+			}
+		}
+	}
+}
+
+// canRetry reports whether a retry waiting for delay would start before
+// RetryDeadline.
+func (c *Client) canRetry(delay time.Duration) bool {
+	return c.RetryDeadline.IsZero() || !now().Add(delay).After(c.RetryDeadline)
+}
+
+// context returns Context, or a context never done if not set.
+func (c *Client) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+// Sleep waits for d, returning early with the error of ctx once it is done,
+// e.g. once the webhook stops.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) retried() {
+	if c.Retried != nil {
+		c.Retried()
+	}
+}
+
+// transport returns the transport of the requests to the GoDaddy API,
+// Transport if set, the default one otherwise.
+func (c *Client) transport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+// NewTransport returns a transport for the requests to the GoDaddy API, meant
+// to be shared by the clients. It keeps more idle connections to the single
+// host of the API than the default transport, and only speaks HTTP/1.1 if
+// disableHTTP2 is set.
+func NewTransport(disableHTTP2 bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	if disableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		// A non-nil empty map disables the upgrade to HTTP/2 over TLS
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
+
+// Domain is a domain of the GoDaddy account.
+type Domain struct {
+	Domain   string `json:"domain"`
+	DomainID int64  `json:"domainId"`
+	Status   string `json:"status"`
+}
+
+// Domains returns the domains the credentials of the client can manage.
+func (c *Client) Domains() ([]Domain, error) {
+	resp, err := c.do(http.MethodGet, "/v1/domains", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return nil, newAPIError("could not list the domains", resp.StatusCode, string(bodyBytes))
+	}
+
+	var domains []Domain
+	if err := json.NewDecoder(resp.Body).Decode(&domains); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not decode the domains: %v", err)
+	}
+	return domains, nil
+}
+
+// Ping checks that the GoDaddy API answers within timeout, to tell
+// connectivity issues apart from failing operations. The probe goes through
+// the transport of the requests, and so through their proxy if any.
+func (c *Client) Ping(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(c.context(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.BaseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	// Any answer tells the API is reachable, the credentials are not sent
+	client := http.Client{
+		Transport: c.transport(),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GoDaddy API %s is unreachable: %v", c.BaseURL, err)
+	}
+	return resp.Body.Close()
+}
+
+// isTransient reports whether a request answered with statusCode may succeed
+// when retried: GoDaddy rate limits aggressively and its gateways
+// occasionally fail.
+func isTransient(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// checkRedirect is the redirect policy of the requests to the GoDaddy API.
+// The credentials are never forwarded to another host than the one of the
+// original request, nor in plaintext once it was sent over https, and no
+// redirect is followed at all if DisableRedirects is set.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.DisableRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	downgraded := via[0].URL.Scheme == "https" && req.URL.Scheme != "https"
+	if req.URL.Host != via[0].URL.Host || downgraded {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// retryDelay returns how long to wait before retrying a request.
+// The Retry-After header is honored when GoDaddy sends one, the exponential
+// backoff configured by RetryBackoff is used otherwise.
+func (c *Client) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d
+			}
+			return 0
+		}
+	}
+
+	backoff := c.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	return Backoff(backoff, attempt)
+}
+
+// Backoff returns the delay before retrying for the given attempt, starting
+// from backoff and doubled after each attempt, but never more than MaxBackoff.
+func Backoff(backoff time.Duration, attempt int) time.Duration {
+	if backoff >= MaxBackoff {
+		return MaxBackoff
+	}
+	// Doubling stops before it may overflow
+	for ; attempt > 0 && backoff < MaxBackoff; attempt-- {
+		backoff *= 2
+	}
+	if backoff > MaxBackoff {
+		return MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,1307 @@
+package godaddy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func txtRecord(data string, ttl int) Record {
+	return Record{Type: "TXT", Name: "_acme-challenge", Data: data, TTL: ttl}
+}
+
+func TestReconcileRetriesOnConflict(t *testing.T) {
+	const path = "/v1/domains/example.com/records/TXT/_acme-challenge"
+
+	existing := []Record{{Type: "TXT", Name: "_acme-challenge", Data: "other", TTL: 600}}
+	var gets, puts int
+	var written []Record
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			gets++
+			json.NewEncoder(w).Encode(existing)
+		case http.MethodPut:
+			puts++
+			if puts == 1 {
+				// A concurrent writer updated the records in the meantime.
+				existing = append(existing, Record{Type: "TXT", Name: "_acme-challenge", Data: "concurrent", TTL: 600})
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &written); err != nil {
+				t.Errorf("invalid body %s: %v", body, err)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if gets != 2 || puts != 2 {
+		t.Errorf("expected 2 GETs and 2 PUTs, got %d and %d", gets, puts)
+	}
+	var data []string
+	for _, r := range written {
+		data = append(data, r.Data)
+	}
+	if strings.Join(data, ",") != "other,concurrent,key" {
+		t.Errorf("unexpected records written: %v", data)
+	}
+}
+
+func TestReconcileGivesUpAfterConflictRetries(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	var retried int
+	c := &Client{BaseURL: srv.URL, ConflictRetries: 1, Retried: func() { retried++ }}
+	_, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 0)}, nil, 0)
+	if !isRetryable(err) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+	if puts != 2 || retried != 1 {
+		t.Errorf("expected 2 PUTs and 1 retry, got %d and %d", puts, retried)
+	}
+}
+
+func TestReplaceRecordsSanitizesTXT(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var records []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil || len(records) != 1 {
+			t.Errorf("unexpected body: %v", err)
+			return
+		}
+		body = records[0]
+	}))
+	defer srv.Close()
+
+	rec := []Record{{
+		Type:     "TXT",
+		Name:     "_acme-challenge",
+		Data:     "key",
+		Priority: 10,
+		Weight:   5,
+		Port:     443,
+		Service:  "_https",
+		Protocol: "_tcp",
+		TTL:      600,
+	}}
+	c := &Client{BaseURL: srv.URL}
+	if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", rec); err != nil {
+		t.Fatalf("ReplaceRecords failed: %v", err)
+	}
+	for _, field := range []string{"priority", "weight", "port", "service", "protocol"} {
+		if _, ok := body[field]; ok {
+			t.Errorf("TXT record was sent with %q: %v", field, body)
+		}
+	}
+	if body["data"] != "key" || body["ttl"] != float64(600) {
+		t.Errorf("unexpected record sent: %v", body)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{500 * time.Millisecond, 0, 500 * time.Millisecond},
+		{500 * time.Millisecond, 3, 4 * time.Second},
+		{time.Second, 6, MaxBackoff},
+		{time.Second, 100, MaxBackoff},
+		{time.Hour, 0, MaxBackoff},
+		{time.Duration(1<<62 - 1), 2, MaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.backoff, tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%v, %d) = %v, expected %v", tt.backoff, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func recordSleeps() (*[]time.Duration, func()) {
+	var delays []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return &delays, func() { sleep = Sleep }
+}
+
+func TestDoRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		client     Client
+		want       []time.Duration
+	}{
+		{
+			name:   "exponential backoff without Retry-After",
+			client: Client{RetryBackoff: 100 * time.Millisecond},
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name: "default backoff without Retry-After",
+			want: []time.Duration{defaultRetryBackoff, 2 * defaultRetryBackoff},
+		},
+		{
+			name:       "Retry-After in seconds",
+			retryAfter: "7",
+			client:     Client{RetryBackoff: 100 * time.Millisecond},
+			want:       []time.Duration{7 * time.Second, 7 * time.Second},
+		},
+		{
+			name:   "service unavailable",
+			status: http.StatusServiceUnavailable,
+			client: Client{RetryBackoff: 100 * time.Millisecond},
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:       "bad gateway with Retry-After",
+			status:     http.StatusBadGateway,
+			retryAfter: "3",
+			want:       []time.Duration{3 * time.Second, 3 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays, restore := recordSleeps()
+			defer restore()
+
+			var calls int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= 2 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					status := tt.status
+					if status == 0 {
+						status = http.StatusTooManyRequests
+					}
+					w.WriteHeader(status)
+					return
+				}
+				w.Write([]byte("[]"))
+			}))
+			defer srv.Close()
+
+			c := tt.client
+			c.BaseURL = srv.URL
+			resp, err := c.do(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatalf("do failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected the request to eventually succeed, got status %d", resp.StatusCode)
+			}
+			if len(*delays) != len(tt.want) {
+				t.Fatalf("expected delays %v, got %v", tt.want, *delays)
+			}
+			for i := range tt.want {
+				if (*delays)[i] != tt.want[i] {
+					t.Errorf("expected delays %v, got %v", tt.want, *delays)
+				}
+			}
+		})
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	delays, restore := recordSleeps()
+	defer restore()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	resp, err := (&Client{BaseURL: srv.URL}).do(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+	resp.Body.Close()
+	if len(*delays) != 0 {
+		t.Errorf("expected no retry, got %d", len(*delays))
+	}
+}
+
+func TestDoRateLimitedGivesUp(t *testing.T) {
+	delays, restore := recordSleeps()
+	defer restore()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, MaxRetries: 2}
+	resp, err := c.do(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || len(*delays) != 2 {
+		t.Errorf("expected 2 retries then status 429, got %d retries and status %d", len(*delays), resp.StatusCode)
+	}
+}
+
+func TestDoRetryDeadline(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	sleep = func(_ context.Context, d time.Duration) error {
+		current = current.Add(d)
+		return nil
+	}
+	defer func() {
+		now = time.Now
+		sleep = Sleep
+	}()
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	start := current
+	c := &Client{BaseURL: srv.URL, MaxRetries: 10, RetryBackoff: 10 * time.Second, RetryDeadline: current.Add(25 * time.Second)}
+	resp, err := c.do(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the last response to be returned, got %d", resp.StatusCode)
+	}
+	// Retried after 10s, the next retry 20s later would pass the deadline
+	if requests != 2 || current.Sub(start) != 10*time.Second {
+		t.Errorf("expected 2 requests within 10s, got %d within %v", requests, current.Sub(start))
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		// Never answer before the request is cancelled
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	go func() {
+		<-received
+		cancel()
+	}()
+	c := &Client{BaseURL: srv.URL, Timeout: time.Minute, Context: ctx}
+	if _, err := c.do(http.MethodGet, "/", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+}
+
+func TestDoCancelledBetweenRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var requests int32
+	answered := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		answered <- struct{}{}
+	}))
+	defer srv.Close()
+
+	go func() {
+		<-answered
+		cancel()
+	}()
+	// The backoff is never waited for once the context is done
+	c := &Client{BaseURL: srv.URL, MaxRetries: 3, RetryBackoff: time.Hour, Context: ctx}
+	if _, err := c.do(http.MethodGet, "/", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the retries to be cancelled, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected no retry once cancelled, got %d requests", n)
+	}
+}
+
+func TestReconcileRetryDeadline(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		puts++
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, ConflictRetries: 3, RetryDeadline: time.Now().Add(-time.Second)}
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 0)}, nil, 0); !isRetryable(err) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+	if puts != 1 {
+		t.Errorf("expected no retry past the deadline, got %d PUTs", puts)
+	}
+}
+
+func TestReconcileKeepsTTLOfRecordsWithoutOne(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  int
+		want int
+	}{
+		{"record TTL", 1200, 1200},
+		{"default TTL", 0, DefaultTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written []map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					w.Write([]byte(`[{"type": "TXT", "name": "_acme-challenge", "data": "other"}]`))
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&written)
+			}))
+			defer srv.Close()
+
+			c := &Client{BaseURL: srv.URL}
+			if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", tt.ttl)}, nil, tt.ttl); err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if len(written) != 2 {
+				t.Fatalf("expected 2 records, got %v", written)
+			}
+			if written[0]["data"] != "other" || written[0]["ttl"] != float64(tt.want) {
+				t.Errorf("expected the existing record to be written with TTL %d, got %v", tt.want, written[0])
+			}
+		})
+	}
+}
+
+func TestReconcileTXTRemoveKeepsTTLOfRecordsWithoutOne(t *testing.T) {
+	var written []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[{"type": "TXT", "name": "_acme-challenge", "data": "other"}, {"type": "TXT", "name": "_acme-challenge", "data": "key"}]`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&written)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	if _, err := c.ReconcileTXT("example.com", "_acme-challenge", nil, []string{"key"}, 1200); err != nil {
+		t.Fatalf("ReconcileTXT failed: %v", err)
+	}
+	if len(written) != 1 || written[0]["data"] != "other" || written[0]["ttl"] != float64(1200) {
+		t.Errorf("expected the remaining record to be written with TTL 1200, got %v", written)
+	}
+}
+
+func TestDoMethodOverride(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       Client
+		method       string
+		wantMethod   string
+		wantOverride string
+	}{
+		{"native PUT", Client{}, http.MethodPut, http.MethodPut, ""},
+		{"tunneled PUT", Client{MethodOverride: true}, http.MethodPut, http.MethodPost, http.MethodPut},
+		{"tunneled DELETE", Client{MethodOverride: true}, http.MethodDelete, http.MethodPost, http.MethodDelete},
+		{"GET is never tunneled", Client{MethodOverride: true}, http.MethodGet, http.MethodGet, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, override string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				override = r.Header.Get("X-HTTP-Method-Override")
+			}))
+			defer srv.Close()
+
+			c := tt.client
+			c.BaseURL = srv.URL
+			resp, err := c.do(tt.method, "/", []byte("[]"))
+			if err != nil {
+				t.Fatalf("do failed: %v", err)
+			}
+			resp.Body.Close()
+			if method != tt.wantMethod || override != tt.wantOverride {
+				t.Errorf("expected %s with override %q, got %s with override %q", tt.wantMethod, tt.wantOverride, method, override)
+			}
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	if BaseURL(true) != ProductionURL || BaseURL(false) != OTEURL {
+		t.Errorf("unexpected base URLs %q and %q", BaseURL(true), BaseURL(false))
+	}
+}
+
+func TestRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/domains/example.com/records/TXT/_acme-challenge" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "sso-key key:secret" {
+			t.Errorf("unexpected Authorization header %q", auth)
+		}
+		w.Write([]byte(`[{"type": "TXT", "name": "_acme-challenge", "data": "value", "ttl": 600}]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIKey: "key", APISecret: "secret"}
+	records, err := c.Records("example.com", "TXT", "_acme-challenge")
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 1 || records[0] != txtRecord("value", 600) {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestRecordsEscapesPath(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath()+" "+r.URL.RawQuery)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	if _, err := c.Records("example.com", "TXT", "_acme-challenge.a b/c?d#e%f"); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	c.PageSize = 10
+	if _, err := c.Records("ex ample.com", "TXT", "@"); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+
+	want := []string{
+		"/v1/domains/example.com/records/TXT/_acme-challenge.a%20b%2Fc%3Fd%23e%25f ",
+		"/v1/domains/ex%20ample.com/records/TXT/@ offset=0&limit=10",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected requests %q, got %q", want, paths)
+	}
+}
+
+func TestRecordsRefusedCredentials(t *testing.T) {
+	tests := []struct {
+		status       int
+		unauthorized bool
+		forbidden    bool
+		hint         string
+	}{
+		{http.StatusUnauthorized, true, false, "GoDaddy rejected the API key and secret"},
+		{http.StatusForbidden, false, true, "the API key is valid but not allowed to manage the domain"},
+		{http.StatusNotFound, false, false, ""},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(`{"code":"REFUSED"}`))
+		}))
+
+		_, err := (&Client{BaseURL: srv.URL}).Records("example.com", "TXT", "_acme-challenge")
+		srv.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Body != `{"code":"REFUSED"}` {
+			t.Errorf("%d: expected an API error with the status and body, got %v", tt.status, err)
+			continue
+		}
+		if got := IsUnauthorized(err); got != tt.unauthorized {
+			t.Errorf("%d: IsUnauthorized() = %v, expected %v", tt.status, got, tt.unauthorized)
+		}
+		if got := IsForbidden(err); got != tt.forbidden {
+			t.Errorf("%d: IsForbidden() = %v, expected %v", tt.status, got, tt.forbidden)
+		}
+		if tt.hint != "" && !strings.Contains(err.Error(), tt.hint) {
+			t.Errorf("%d: expected the error to contain %q, got %v", tt.status, tt.hint, err)
+		}
+		if wrapped := fmt.Errorf("presenting failed: %w", err); IsUnauthorized(wrapped) != tt.unauthorized || IsForbidden(wrapped) != tt.forbidden {
+			t.Errorf("%d: expected the wrapped error to keep its type", tt.status)
+		}
+	}
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		code    string
+		message string
+		fields  []ErrorField
+	}{
+		{
+			http.StatusUnprocessableEntity,
+			`{"code": "DUPLICATE_RECORD", "message": "Another record with the same attributes already exists"}`,
+			"DUPLICATE_RECORD", "Another record with the same attributes already exists", nil,
+		},
+		{
+			http.StatusBadRequest,
+			`{"code": "INVALID_BODY", "message": "Request body doesn't fulfill schema, see details in ` + "`fields`" + `",
+			  "fields": [{"code": "UNEXPECTED_TYPE", "message": "is not a array", "path": "records", "pathRelated": "records"}]}`,
+			"INVALID_BODY", "Request body doesn't fulfill schema, see details in `fields`",
+			[]ErrorField{{Path: "records", PathRelated: "records", Code: "UNEXPECTED_TYPE", Message: "is not a array"}},
+		},
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, "", "", nil},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+
+		c := &Client{BaseURL: srv.URL, MaxRetries: 1}
+		sleep = func(context.Context, time.Duration) error { return nil }
+		err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)})
+		sleep = Sleep
+		srv.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%d: expected an API error, got %v", tt.status, err)
+		}
+		if apiErr.StatusCode != tt.status || apiErr.Code != tt.code || apiErr.Message != tt.message {
+			t.Errorf("%d: got status %d, code %q and message %q", tt.status, apiErr.StatusCode, apiErr.Code, apiErr.Message)
+		}
+		if fmt.Sprint(apiErr.Fields) != fmt.Sprint(tt.fields) {
+			t.Errorf("%d: expected fields %v, got %v", tt.status, tt.fields, apiErr.Fields)
+		}
+		for _, part := range []string{tt.code, tt.message} {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%d: expected %q in the error, got %v", tt.status, part, err)
+			}
+		}
+	}
+}
+
+func TestDomains(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/domains" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"domainId": 1234, "domain": "example.com", "status": "ACTIVE", "expires": "2027-01-01T00:00:00.000Z"},
+			{"domainId": 5678, "domain": "example.org", "status": "CANCELLED"}
+		]`))
+	}))
+	defer srv.Close()
+
+	domains, err := (&Client{BaseURL: srv.URL}).Domains()
+	if err != nil {
+		t.Fatalf("Domains failed: %v", err)
+	}
+	want := []Domain{{Domain: "example.com", DomainID: 1234, Status: "ACTIVE"}, {Domain: "example.org", DomainID: 5678, Status: "CANCELLED"}}
+	if fmt.Sprint(domains) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, domains)
+	}
+}
+
+func TestDeleteRecords(t *testing.T) {
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/v1/domains/example.com/records/TXT/_acme-challenge" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	status = http.StatusNoContent
+	if err := c.DeleteRecords("example.com", "TXT", "_acme-challenge"); err != nil {
+		t.Fatalf("DeleteRecords failed: %v", err)
+	}
+
+	status = http.StatusNotFound
+	err := c.DeleteRecords("example.com", "TXT", "_acme-challenge")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an API error with status 404, got %v", err)
+	}
+}
+
+func TestCheckResponseBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code": "INVALID_BODY", "message": "Request body doesn't fulfill schema"}`))
+	}))
+	defer srv.Close()
+
+	rec := []Record{txtRecord("key", 600)}
+
+	c := &Client{BaseURL: srv.URL}
+	if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", rec); err != nil {
+		t.Errorf("expected the body to be ignored by default, got %v", err)
+	}
+
+	c.CheckResponseBody = true
+	err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", rec)
+	if err == nil || !strings.Contains(err.Error(), "INVALID_BODY") {
+		t.Errorf("expected ReplaceRecords to fail with the error of the body, got %v", err)
+	}
+	err = c.DeleteRecords("example.com", "TXT", "_acme-challenge")
+	if err == nil || !strings.Contains(err.Error(), "INVALID_BODY") {
+		t.Errorf("expected DeleteRecords to fail with the error of the body, got %v", err)
+	}
+}
+
+func TestCheckResponseBodyWithoutError(t *testing.T) {
+	for _, body := range []string{"", "{}", "[]"} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+
+		c := &Client{BaseURL: srv.URL, CheckResponseBody: true}
+		if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)}); err != nil {
+			t.Errorf("unexpected error for body %q: %v", body, err)
+		}
+		srv.Close()
+	}
+}
+
+func TestEmptySuccessBody(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+		var puts int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut {
+				puts++
+				w.WriteHeader(status)
+			}
+			// Neither the records nor the write come with a body
+		}))
+
+		for _, checkBody := range []bool{false, true} {
+			c := &Client{BaseURL: srv.URL, CheckResponseBody: checkBody}
+			if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)}, nil, 600); err != nil {
+				t.Errorf("%d: expected an empty body to succeed, got %v", status, err)
+			}
+		}
+		if puts != 2 {
+			t.Errorf("%d: expected the record to be written each time, got %d writes", status, puts)
+		}
+		srv.Close()
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	var auth []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}))
+	defer target.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIKey: "key", APISecret: "secret"}
+	if _, err := c.Records("example.com", "TXT", "_acme-challenge"); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(auth) != 1 || auth[0] != "" {
+		t.Errorf("expected the credentials not to be forwarded to another host, got %q", auth)
+	}
+
+	auth = nil
+	c.DisableRedirects = true
+	_, err := c.Records("example.com", "TXT", "_acme-challenge")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect to be returned as an error, got %v", err)
+	}
+	if len(auth) != 0 {
+		t.Errorf("expected the redirect not to be followed")
+	}
+}
+
+func TestRedirectsOnSameHost(t *testing.T) {
+	var auth string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moved" {
+			http.Redirect(w, r, srv.URL+"/moved", http.StatusFound)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIKey: "key", APISecret: "secret"}
+	if _, err := c.Records("example.com", "TXT", "_acme-challenge"); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if auth != "sso-key key:secret" {
+		t.Errorf("expected the credentials to be kept on the same host, got %q", auth)
+	}
+}
+
+func TestRedirectsDowngraded(t *testing.T) {
+	c := &Client{}
+	original := httptest.NewRequest(http.MethodGet, "https://api.godaddy.com/v1/domains", nil)
+	for _, tt := range []struct {
+		url  string
+		kept bool
+	}{
+		{"https://api.godaddy.com/v1/domains/moved", true},
+		{"http://api.godaddy.com/v1/domains/moved", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		req.Header.Set("Authorization", "sso-key key:secret")
+		if err := c.checkRedirect(req, []*http.Request{original}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+		if kept := req.Header.Get("Authorization") != ""; kept != tt.kept {
+			t.Errorf("%s: expected the credentials to be kept: %v, got %v", tt.url, tt.kept, kept)
+		}
+	}
+}
+
+func TestReconcileSortRecords(t *testing.T) {
+	for _, existing := range []string{
+		`[{"type":"TXT","name":"_acme-challenge","data":"c"},{"type":"TXT","name":"_acme-challenge","data":"a"}]`,
+		`[{"type":"TXT","name":"_acme-challenge","data":"a"},{"type":"TXT","name":"_acme-challenge","data":"c"}]`,
+	} {
+		var written []Record
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.Write([]byte(existing))
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&written)
+		}))
+
+		c := &Client{BaseURL: srv.URL, SortRecords: true}
+		if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("b", 600)}, nil, 600); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+		srv.Close()
+
+		var data []string
+		for _, r := range written {
+			data = append(data, r.Data)
+		}
+		if strings.Join(data, ",") != "a,b,c" {
+			t.Errorf("expected the records to be sorted, got %v", data)
+		}
+	}
+}
+
+func TestReconcileDropsDuplicateValues(t *testing.T) {
+	var written []Record
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[{"type":"TXT","name":"_acme-challenge","data":"a","ttl":600},{"type":"TXT","name":"_acme-challenge","data":"a","ttl":600}]`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&written)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("b", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(written) != 2 || written[0].Data != "a" || written[1].Data != "b" {
+		t.Errorf("expected duplicate values to be written once, got %v", written)
+	}
+}
+
+func TestCAARecordsAreNeverModified(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	caa := Record{Type: "CAA", Name: "@", Data: `0 issue "letsencrypt.org"`}
+
+	if err := c.ReplaceRecords("example.com", "CAA", "@", []Record{caa}); err == nil {
+		t.Errorf("expected replacing CAA records to be refused")
+	}
+	if err := c.ReplaceRecords("example.com", "TXT", "@", []Record{caa}); err == nil {
+		t.Errorf("expected writing a CAA record to be refused")
+	}
+	if err := c.DeleteRecords("example.com", "caa", "@"); err == nil {
+		t.Errorf("expected deleting CAA records to be refused")
+	}
+	if _, err := c.Reconcile("example.com", "CAA", "@", []Record{caa}, nil, 0); err == nil {
+		t.Errorf("expected adding a CAA record to be refused")
+	}
+	if requests > 1 {
+		t.Errorf("expected no CAA record to be written, got %d requests", requests)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	var traced []string
+	c := &Client{
+		BaseURL: srv.URL,
+		Trace: func(method string) func(int) {
+			traced = append(traced, "start "+method)
+			return func(statusCode int) { traced = append(traced, fmt.Sprintf("done %s %d", method, statusCode)) }
+		},
+	}
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("a", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	want := []string{"start GET", "done GET 200", "start PUT", "done PUT 200"}
+	if strings.Join(traced, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v to be traced, got %v", want, traced)
+	}
+}
+
+func TestNewTransportDisableHTTP2(t *testing.T) {
+	if (&Client{}).transport() != http.DefaultTransport {
+		t.Errorf("expected the default transport without Transport")
+	}
+	shared := NewTransport(false)
+	if (&Client{Transport: shared}).transport() != shared {
+		t.Errorf("expected the configured transport to be used")
+	}
+
+	transport := NewTransport(true)
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Errorf("expected HTTP/2 to be disabled, got ForceAttemptHTTP2 %v and TLSNextProto %v", transport.ForceAttemptHTTP2, transport.TLSNextProto)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 1 {
+			t.Errorf("expected HTTP/1.1, got %s", r.Proto)
+		}
+		w.Write([]byte(`[]`))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRecordsStrictDecoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"type":"TXT","name":"_acme-challenge","data":"a","ttl":600,"tags":["new"]}]`))
+	}))
+	defer srv.Close()
+
+	records, err := (&Client{BaseURL: srv.URL}).Records("example.com", "TXT", "_acme-challenge")
+	if err != nil || len(records) != 1 || records[0].Data != "a" {
+		t.Errorf("expected unknown fields to be ignored, got %v and %v", records, err)
+	}
+
+	_, err = (&Client{BaseURL: srv.URL, StrictDecoding: true}).Records("example.com", "TXT", "_acme-challenge")
+	if err == nil || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("expected unknown fields to be rejected, got %v", err)
+	}
+}
+
+func TestRecordsPagination(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var page []Record
+		for i := offset; i < 5 && i < offset+limit; i++ {
+			page = append(page, txtRecord(strconv.Itoa(i), 600))
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	records, err := (&Client{BaseURL: srv.URL, PageSize: 2}).Records("example.com", "TXT", "_acme-challenge")
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 5 || records[4].Data != "4" {
+		t.Errorf("expected the records of every page, got %v", records)
+	}
+	want := []string{"offset=0&limit=2", "offset=2&limit=2", "offset=4&limit=2"}
+	if strings.Join(queries, ",") != strings.Join(want, ",") {
+		t.Errorf("expected the pages %v to be requested, got %v", want, queries)
+	}
+}
+
+func TestRecordsPaginationBounded(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Ignores the offset, always answering the first page
+		json.NewEncoder(w).Encode([]Record{txtRecord("0", 600), txtRecord("1", 600)})
+	}))
+	defer srv.Close()
+
+	_, err := (&Client{BaseURL: srv.URL, PageSize: 2}).Records("example.com", "TXT", "_acme-challenge")
+	if err == nil || !strings.Contains(err.Error(), "same page") {
+		t.Errorf("expected a repeated page to fail, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected to stop after the repeated page, got %d requests", requests)
+	}
+
+	requests = 0
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		// Never ends, each page being full
+		json.NewEncoder(w).Encode([]Record{txtRecord(strconv.Itoa(offset), 600)})
+	})
+	_, err = (&Client{BaseURL: srv.URL, PageSize: 1}).Records("example.com", "TXT", "_acme-challenge")
+	if err == nil || !strings.Contains(err.Error(), "pages") {
+		t.Errorf("expected endless pages to fail, got %v", err)
+	}
+	if requests != maxPages {
+		t.Errorf("expected %d pages to be read, got %d requests", maxPages, requests)
+	}
+}
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	c := &Client{BaseURL: srv.URL}
+	if err := c.Ping(time.Second); err != nil {
+		t.Errorf("expected the API to be reachable, got %v", err)
+	}
+
+	srv.Close()
+	start := time.Now()
+	err := c.Ping(time.Second)
+	if err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("expected the API to be unreachable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the probe to fail fast, took %v", elapsed)
+	}
+}
+
+func TestPingThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	// The API itself cannot be resolved, only the proxy can reach it
+	c := &Client{BaseURL: "http://api.godaddy.invalid", Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	if err := c.Ping(time.Second); err != nil {
+		t.Fatalf("expected the API to be reachable through the proxy, got %v", err)
+	}
+	if proxied != "api.godaddy.invalid" {
+		t.Errorf("expected the probe to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestReplaceRecordsContentLength(t *testing.T) {
+	for _, disableChunked := range []bool{false, true} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.ContentLength != int64(len(body)) || r.Header.Get("Content-Length") == "" {
+				t.Errorf("expected a Content-Length of %d, got %d", len(body), r.ContentLength)
+			}
+			if len(r.TransferEncoding) != 0 {
+				t.Errorf("expected no transfer encoding, got %v", r.TransferEncoding)
+			}
+		}))
+
+		c := &Client{BaseURL: srv.URL, DisableChunked: disableChunked}
+		if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", []Record{txtRecord("a", 600)}); err != nil {
+			t.Errorf("ReplaceRecords failed: %v", err)
+		}
+		srv.Close()
+	}
+}
+
+func TestReconcileMaxValues(t *testing.T) {
+	var written []Record
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode([]Record{txtRecord("a", 600), txtRecord("b", 600), txtRecord("c", 600)})
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&written)
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, MaxValues: 3}
+	_, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("d", 600)}, nil, 600)
+	if err == nil || !strings.Contains(err.Error(), "already holds 3 values") {
+		t.Errorf("expected the limit to be reported, got %v", err)
+	}
+	if written != nil {
+		t.Errorf("expected nothing to be written, got %v", written)
+	}
+
+	// Without values known to be stale, nothing may be pruned
+	c.PruneOnLimit = true
+	_, err = c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("d", 600)}, nil, 600)
+	if err == nil || written != nil {
+		t.Errorf("expected the limit to be reported without stale values, got %v and %v", err, written)
+	}
+
+	c.Stale = func(data string) bool { return data == "b" }
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("d", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	var values []string
+	for _, r := range written {
+		values = append(values, r.Data)
+	}
+	if strings.Join(values, ",") != "a,c,d" {
+		t.Errorf("expected only the stale value to be pruned, got %v", values)
+	}
+
+	written = nil
+	c.MaxValues = 2
+	_, err = c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("d", 600)}, nil, 600)
+	if err == nil || written != nil {
+		t.Errorf("expected the limit to be reported with too few stale values, got %v and %v", err, written)
+	}
+}
+
+func TestReconcileIgnoreQuotes(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode([]Record{txtRecord(`"key"`, 600)})
+			return
+		}
+		puts++
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, IgnoreQuotes: true}
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("expected the quoted value to match the key, got %d writes", puts)
+	}
+
+	c.IgnoreQuotes = false
+	if _, err := c.Reconcile("example.com", "TXT", "_acme-challenge", []Record{txtRecord("key", 600)}, nil, 600); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if puts != 1 {
+		t.Errorf("expected the quoted value to differ from the key by default, got %d writes", puts)
+	}
+}
+
+func TestReconcileTXTIgnoreQuotes(t *testing.T) {
+	var method string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode([]Record{txtRecord(`"key"`, 600)})
+			return
+		}
+		method = r.Method
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, IgnoreQuotes: true}
+	changed, err := c.ReconcileTXT("example.com", "_acme-challenge", nil, []string{"key"}, 600)
+	if err != nil {
+		t.Fatalf("ReconcileTXT failed: %v", err)
+	}
+	if !changed || method != http.MethodDelete {
+		t.Errorf("expected the quoted value to be removed, got changed %v and %s", changed, method)
+	}
+}
+
+func TestReplaceRecordsBodySchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{"", `[{"type":"TXT","name":"_acme-challenge","data":"a","ttl":600}]`},
+		{SchemaV1, `[{"type":"TXT","name":"_acme-challenge","data":"a","ttl":600}]`},
+		{SchemaV2, `[{"data":"a","ttl":600}]`},
+	}
+	for _, tt := range tests {
+		var body string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+		}))
+
+		c := &Client{BaseURL: srv.URL, BodySchema: tt.schema}
+		if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", []Record{txtRecord("a", 600)}); err != nil {
+			t.Errorf("ReplaceRecords with schema %q failed: %v", tt.schema, err)
+		}
+		if body != tt.want {
+			t.Errorf("expected body %s with schema %q, got %s", tt.want, tt.schema, body)
+		}
+		srv.Close()
+	}
+
+	c := &Client{BodySchema: "v3"}
+	if err := c.ReplaceRecords("example.com", "TXT", "_acme-challenge", []Record{txtRecord("a", 600)}); err == nil {
+		t.Errorf("expected an unknown schema to be rejected")
+	}
+}
+
+func TestReconcileTXT(t *testing.T) {
+	tests := []struct {
+		name        string
+		add         []string
+		remove      []string
+		wantChanged bool
+		want        string
+	}{
+		{"add only", []string{"c"}, nil, true, "a,b,c"},
+		{"remove only", nil, []string{"a"}, true, "b"},
+		{"mixed", []string{"c", "d"}, []string{"a", "b"}, true, "c,d"},
+		{"already reconciled", []string{"a"}, []string{"c"}, false, ""},
+		{"remove all", nil, []string{"a", "b"}, true, "deleted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written []Record
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					json.NewEncoder(w).Encode([]Record{txtRecord("a", 600), txtRecord("b", 600)})
+				case http.MethodDelete:
+					written = []Record{{Data: "deleted"}}
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					json.NewDecoder(r.Body).Decode(&written)
+				}
+			}))
+			defer srv.Close()
+
+			changed, err := (&Client{BaseURL: srv.URL}).ReconcileTXT("example.com", "_acme-challenge", tt.add, tt.remove, 600)
+			if err != nil {
+				t.Fatalf("ReconcileTXT failed: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("expected changed to be %v", tt.wantChanged)
+			}
+			var data []string
+			for _, r := range written {
+				data = append(data, r.Data)
+			}
+			if strings.Join(data, ",") != tt.want {
+				t.Errorf("expected %q to be written, got %q", tt.want, strings.Join(data, ","))
+			}
+		})
+	}
+}
+
+func TestAuthorizationHeader(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header["Authorization"]
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL, APIKey: "Key-1", APISecret: "Secret-1"}
+	if _, err := c.Records("example.com", "TXT", "_acme-challenge"); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	// GoDaddy rejects any other case of the scheme
+	if len(auth) != 1 || auth[0] != "sso-key Key-1:Secret-1" {
+		t.Errorf("expected the Authorization header %q, got %q", "sso-key Key-1:Secret-1", auth)
+	}
+}
+
+func TestShopperIDHeader(t *testing.T) {
+	for _, shopperID := range []string{"", "123456"} {
+		var header []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header["X-Shopper-Id"]
+			w.Write([]byte(`[]`))
+		}))
+
+		c := &Client{BaseURL: srv.URL, ShopperID: shopperID}
+		_, err := c.Records("example.com", "TXT", "_acme-challenge")
+		srv.Close()
+		if err != nil {
+			t.Fatalf("Records failed: %v", err)
+		}
+
+		switch {
+		case shopperID == "" && header != nil:
+			t.Errorf("expected no X-Shopper-Id header, got %q", header)
+		case shopperID != "" && (len(header) != 1 || header[0] != shopperID):
+			t.Errorf("expected X-Shopper-Id %q, got %q", shopperID, header)
+		}
+	}
+}
+
+func TestHeadersForLog(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Request-Id", "abc123")
+	header.Set("X-RateLimit-Remaining", "59")
+	header.Set("Set-Cookie", "session=secret")
+	header.Set("Authorization", "sso-key key:secret")
+	header.Set("Content-Type", "application/json")
+
+	got := headersForLog(header)
+	if want := "X-Request-Id=abc123 X-RateLimit-Remaining=59"; got != want {
+		t.Errorf("headersForLog() = %q, expected %q", got, want)
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("expected no credentials in %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// latencyLog accumulates the duration of the phases of an operation, e.g. the
+// zone lookup and the requests sent to GoDaddy, to log them once done.
+type latencyLog struct {
+	start     time.Time
+	phases    []string
+	durations map[string]time.Duration
+}
+
+func newLatencyLog() *latencyLog {
+	return &latencyLog{start: now(), durations: map[string]time.Duration{}}
+}
+
+// observe adds d to the duration of phase.
+func (l *latencyLog) observe(phase string, d time.Duration) {
+	if _, ok := l.durations[phase]; !ok {
+		l.phases = append(l.phases, phase)
+	}
+	l.durations[phase] += d
+}
+
+// trace starts timing phase, until the returned function is called.
+func (l *latencyLog) trace(phase string) func() {
+	start := now()
+	return func() {
+		l.observe(phase, now().Sub(start))
+	}
+}
+
+func (l *latencyLog) String() string {
+	phases := make([]string, len(l.phases))
+	for i, phase := range l.phases {
+		phases[i] = fmt.Sprintf("%s: %v", phase, l.durations[phase])
+	}
+	return fmt.Sprintf("%v (%s)", now().Sub(l.start), strings.Join(phases, ", "))
+}
+
+// startLatencyLog starts timing the operation if LogLatency is set. The
+// returned function logs the durations at LatencyVerbosity.
+func startLatencyLog(cfg *godaddyDNSProviderConfig, action string, fqdn string) func() {
+	if !cfg.LogLatency {
+		return func() {}
+	}
+	cfg.latency = newLatencyLog()
+	return func() {
+		klog.V(klog.Level(cfg.LatencyVerbosity)).Infof("%s %q took %v", action, fqdn, cfg.latency)
+	}
+}
+
+// tracePhase starts timing phase of the operation of cfg, if any.
+func tracePhase(cfg godaddyDNSProviderConfig, phase string) func() {
+	if cfg.latency == nil {
+		return func() {}
+	}
+	return cfg.latency.trace(phase)
+}
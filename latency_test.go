@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLogLatency(t *testing.T) {
+	clock, restore := fakeClock()
+	defer restore()
+
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			*clock = clock.Add(100 * time.Millisecond)
+			w.Write([]byte(`[]`))
+		case http.MethodPut:
+			*clock = clock.Add(200 * time.Millisecond)
+		}
+	}))
+	defer cleanup()
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		*clock = clock.Add(50 * time.Millisecond)
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	logs := captureLogsAt("0", func() {
+		if err := c.Present(withConfig(t, ch, `{"logLatency": true}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	want := `Presenting "_acme-challenge.example.com." took 350ms (zone lookup: 50ms, GET: 100ms, PUT: 200ms)`
+	if !strings.Contains(logs, want) {
+		t.Errorf("expected the latency to be logged as %q, got:\n%s", want, logs)
+	}
+
+	logs = captureLogsAt("3", func() {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+		if err := c.Present(withConfig(t, ch, `{"logLatency": true, "latencyVerbosity": 4}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if strings.Contains(logs, "took") {
+		t.Errorf("expected no latency log when disabled or above the verbosity, got:\n%s", logs)
+	}
+
+	logs = captureLogsAt("4", func() {
+		if err := c.Present(withConfig(t, ch, `{"logLatency": true, "latencyVerbosity": 4}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if !strings.Contains(logs, "took") {
+		t.Errorf("expected the latency to be logged at its verbosity, got:\n%s", logs)
+	}
+}
+
+func TestLogSecretReads(t *testing.T) {
+	clock, restore := fakeClock()
+	defer restore()
+
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer cleanup()
+	client := fake.NewSimpleClientset(
+		newSecret("challenge", "godaddy", map[string]string{"key": "the-key", "secret": "the-secret"}),
+	)
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*clock = clock.Add(250 * time.Millisecond)
+		return false, nil, nil
+	})
+	c.client = client
+
+	logs := captureLogsAt("0", func() {
+		if err := c.Present(withConfig(t, ch, `{"logSecretReads": true}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	want := `Reading secret "challenge/godaddy" took 250ms`
+	if strings.Count(logs, want) != 2 {
+		t.Errorf("expected the reads of the key and of the secret to be logged as %q, got:\n%s", want, logs)
+	}
+
+	logs = captureLogsAt("3", func() {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+		if err := c.Present(withConfig(t, ch, `{"logSecretReads": true, "latencyVerbosity": 4}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if strings.Contains(logs, "Reading secret") {
+		t.Errorf("expected no secret read log when disabled or above the verbosity, got:\n%s", logs)
+	}
+
+	logs = captureLogsAt("4", func() {
+		if err := c.Present(withConfig(t, ch, `{"logSecretReads": true, "latencyVerbosity": 4}`)); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if !strings.Contains(logs, "Reading secret") {
+		t.Errorf("expected the secret reads to be logged at their verbosity, got:\n%s", logs)
+	}
+}
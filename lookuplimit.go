@@ -0,0 +1,37 @@
+package main
+
+import "sync"
+
+// lookupLimiter bounds the number of zone lookups running at once. The limit
+// is given by each caller, as it comes from the configuration of the
+// challenge. The zero value is ready to use.
+type lookupLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int
+}
+
+// acquire waits until less than limit lookups are running, and returns the
+// function ending the lookup. Lookups are not limited if limit is 0.
+func (l *lookupLimiter) acquire(limit int) func() {
+	if limit <= 0 {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+	}
+	for l.inFlight >= limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+		l.cond.Broadcast()
+	}
+}
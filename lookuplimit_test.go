@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+func TestGetZoneMaxConcurrentLookups(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// Leave time for the other lookups to overlap
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return fqdn, nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	for _, limit := range []int{0, 2} {
+		c := &godaddyDNSSolver{}
+		cfg := godaddyDNSProviderConfig{MaxConcurrentZoneLookups: limit}
+		maxInFlight = 0
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := c.getZone(cfg, fmt.Sprintf("example%d.com.", i)); err != nil {
+					t.Errorf("getZone failed: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		switch {
+		case limit > 0 && maxInFlight > limit:
+			t.Errorf("expected at most %d lookups at once, got %d", limit, maxInFlight)
+		case limit == 0 && maxInFlight <= 2:
+			t.Errorf("expected the lookups not to be limited, got %d at once", maxInFlight)
+		}
+	}
+}
@@ -2,56 +2,147 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog"
 
+	"github.com/jetstack/cert-manager/pkg/acme/webhook"
 	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
 	"github.com/jetstack/cert-manager/pkg/acme/webhook/cmd"
 	certmgrv1 "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
 
-	pkgutil "github.com/jetstack/cert-manager/pkg/util"
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
 )
 
 const providerName = "godaddy"
 
+// challengePrefix is the label prefixing the names of the DNS-01 challenges.
+const challengePrefix = "_acme-challenge"
+
 // GroupName a API group name
 var GroupName = os.Getenv("GROUP_NAME")
 
-// DNSRecord a DNS record
-type DNSRecord struct {
-	Type     string `json:"type"`
-	Name     string `json:"name"`
-	Data     string `json:"data"`
-	Priority int    `json:"priority,omitempty"`
-	TTL      int    `json:"ttl,omitempty"`
-}
-
 func main() {
 	if GroupName == "" {
 		panic("GROUP_NAME must be specified")
 	}
 
+	defaults, err := defaultConfigFromEnv(os.Getenv)
+	if err != nil {
+		panic(err)
+	}
+
+	solvers, err := newSolvers(defaults, os.Getenv("SOLVERS"))
+	if err != nil {
+		panic(err)
+	}
+
 	// This will register our godaddy DNS provider with the webhook serving
 	// library, making it available as an API under the provided GroupName.
 	// You can register multiple DNS provider implementations with a single
 	// webhook, where the Name() method will be used to disambiguate between
 	// the different implementations.
-	cmd.RunWebhookServer(GroupName,
-		&godaddyDNSSolver{},
-	)
+	cmd.RunWebhookServer(GroupName, solvers...)
+}
+
+// solverInstance describes a solver registered under its own name, with the
+// configuration used as default for the issuers referencing it.
+type solverInstance struct {
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config"`
+}
+
+// defaultConfigFromEnv returns the configuration every challenge config is
+// applied to, decoded from GODADDY_CONFIG, along with the credentials of
+// GODADDY_API_KEY and GODADDY_API_SECRET used when no secret is referenced,
+// and the namespace of WEBHOOK_SECRET_NAMESPACE the secrets are read from
+// unless their reference sets one. This lets single-tenant deployments be
+// configured without issuer config.
+func defaultConfigFromEnv(getenv func(string) string) (godaddyDNSProviderConfig, error) {
+	var cfg godaddyDNSProviderConfig
+	if raw := getenv("GODADDY_CONFIG"); raw != "" {
+		var err error
+		cfg, err = loadConfig(&apiext.JSON{Raw: []byte(raw)}, cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("GODADDY_CONFIG: %v", err)
+		}
+	}
+	cfg.envAPIKey = getenv("GODADDY_API_KEY")
+	cfg.envAPISecret = getenv("GODADDY_API_SECRET")
+	if (cfg.envAPIKey == "") != (cfg.envAPISecret == "") {
+		return cfg, errors.New("GODADDY_API_KEY and GODADDY_API_SECRET must be set together")
+	}
+	cfg.envSecretNamespace = getenv("WEBHOOK_SECRET_NAMESPACE")
+	return cfg, nil
+}
+
+// newSolvers creates the solvers described by spec, a JSON list of instances
+// such as `[{"name": "godaddy-prod", "config": {"production": true}}]`, with
+// the configuration of each instance applied to base, and customized by opts.
+// A single solver named after the provider is created if spec is empty.
+func newSolvers(base godaddyDNSProviderConfig, spec string, opts ...solverOption) ([]webhook.Solver, error) {
+	if spec == "" {
+		return []webhook.Solver{newSolver("", base, opts)}, nil
+	}
+
+	var instances []solverInstance
+	if err := json.Unmarshal([]byte(spec), &instances); err != nil {
+		return nil, fmt.Errorf("error decoding solver instances: %v", err)
+	}
+	if len(instances) == 0 {
+		return nil, errors.New("no solver instance defined")
+	}
+
+	names := map[string]bool{}
+	solvers := make([]webhook.Solver, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Name == "" {
+			return nil, errors.New("solver instance without name")
+		}
+		if names[instance.Name] {
+			return nil, fmt.Errorf("duplicate solver instance %q", instance.Name)
+		}
+		names[instance.Name] = true
+
+		defaults := base
+		if len(instance.Config) > 0 {
+			var err error
+			defaults, err = loadConfig(&apiext.JSON{Raw: instance.Config}, defaults)
+			if err != nil {
+				return nil, fmt.Errorf("solver instance %q: %v", instance.Name, err)
+			}
+		}
+		solvers = append(solvers, newSolver(instance.Name, defaults, opts))
+	}
+	return solvers, nil
+}
+
+func newSolver(name string, defaults godaddyDNSProviderConfig, opts []solverOption) *godaddyDNSSolver {
+	c := &godaddyDNSSolver{name: name, defaults: defaults}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // godaddyDNSSolver implements the provider-specific logic needed to
@@ -59,7 +150,41 @@ func main() {
 // To do so, it must implement the `github.com/jetstack/cert-manager/pkg/acme/webhook.Solver`
 // interface.
 type godaddyDNSSolver struct {
-	client *kubernetes.Clientset
+	// mu guards client and ctx, which Initialize may set concurrently
+	mu     sync.Mutex
+	client kubernetes.Interface
+	// ctx is done once the webhook stops, cancelling the requests in flight
+	ctx context.Context
+	// name overrides the name of the solver, providerName is used if empty
+	name string
+	// defaults is the configuration the issuer configuration is applied to
+	defaults godaddyDNSProviderConfig
+	// presented remembers the challenges recently presented
+	presented recentCache
+	// cleanups coalesces the cleanups of the same record name
+	cleanups cleanupBatcher
+	// zones serializes the writes to each zone when SerializeZones is set
+	zones zoneLocks
+	// zoneCache remembers the zones found through DNS
+	zoneCache zoneCache
+	// lastZones remembers the last zone found for each name and nameservers
+	lastZones lastKnownZones
+	// zoneLookups bounds the zone lookups running at once
+	zoneLookups lookupLimiter
+	// quota counts the challenge records presented against RecordQuota
+	quota recordQuota
+	// stale remembers the values cleaned up, which recordLimit may prune
+	stale staleValues
+	// live remembers the values presented and not cleaned up yet, which
+	// removeOrphans removes once old enough
+	live presentedValues
+	// credentials fetches the API credentials, Kubernetes Secrets are read
+	// if nil
+	credentials credentialProvider
+	// transports sends the requests of all the operations to GoDaddy
+	transports apiTransports
+	// writes spaces out the writes to GoDaddy when SequenceInterval is set
+	writes sequencer
 }
 
 // godaddyDNSProviderConfig is a structure that is used to decode into when
@@ -80,33 +205,488 @@ type godaddyDNSProviderConfig struct {
 	// These fields will be set by users in the
 	// `issuer.spec.acme.dns01.providers.webhook.config` field.
 
-	APIKeyRef certmgrv1.SecretKeySelector `json:"apiKeyRef"`
-	APISecretRef certmgrv1.SecretKeySelector `json:"apiSecretRef"`
+	APIKeyRef    secretKeySelector `json:"apiKeyRef"`
+	APISecretRef secretKeySelector `json:"apiSecretRef"`
+
+	// +optional. Skip the cleanups whose referenced secret or key no longer
+	// exists, with a warning, instead of failing them
+	IgnoreMissingCredentialsOnCleanup bool `json:"ignoreMissingCredentialsOnCleanup"`
+	// +optional. Secret holding both the API key and secret, instead of
+	// apiKeyRef and apiSecretRef
+	CredentialsSecretRef credentialsSecretRef `json:"credentialsSecretRef"`
+
+	// +optional. Additional credentials, tried in order when GoDaddy rejects
+	// the previous ones
+	Credentials []credentialRefs `json:"credentials"`
 
 	AuthAPIKey    string `json:"authApiKey"`
 	AuthAPISecret string `json:"authApiSecret"`
-	Production    bool   `json:"production"`
+	// +optional. Credentials used when both referenced secrets and inline
+	// authApiKey and authApiSecret are set: secret (default) or inline. The
+	// other ones are ignored with a warning
+	CredentialPrecedence string `json:"credentialPrecedence"`
+	Production           bool   `json:"production"`
+	// +optional. When GoDaddy refuses the credentials in OTE before anything
+	// was written, retry once against production and keep using it for the
+	// operation. Production is never retried against OTE. Cannot be used
+	// along with apiBaseURL
+	AutoDetectEnv bool `json:"autoDetectEnv"`
+	// +optional. TEST ONLY: value presented instead of the challenge key, for
+	// reproducible integration tests against OTE. Rejected in production
+	TestOnlyKey string `json:"testOnlyKey"`
+	// +optional. Numeric ID of the customer a reseller account manages the
+	// domain for, sent as X-Shopper-Id
+	ShopperID string `json:"shopperId"`
+	// +optional. URL of the GoDaddy API, e.g. of a gateway or a mock,
+	// overriding the OTE or production one. Must use https unless the host is
+	// local
+	BaseURL string `json:"apiBaseURL"`
 
-	// +optional. The TTL of the TXT record used for the DNS challenge
-	TTL           int    `json:"ttl"`
-	// +optional.  API request timeout
+	// +optional. The TTL of the TXT record used for the DNS challenge, 600 by
+	// default. Lower TTLs are raised to 600, the minimum accepted by GoDaddy
+	TTL int `json:"ttl"`
+	// +optional. Strategy used to find the zone of the record: ns (default),
+	// soa, or publicSuffix to take the registrable domain of the name after
+	// the public suffix list bundled with golang.org/x/net, without DNS lookup
+	ZoneDetection string `json:"zoneDetection"`
+	// +optional. Suffixes, e.g. custom TLDs, of the zones found without DNS
+	// lookup: the zone of a name is the label preceding the suffix along
+	// with the suffix
+	KnownZoneSuffixes []string `json:"knownZoneSuffixes"`
+	// +optional. Time in seconds the zone found for a name is cached, 300 by
+	// default. Disabled if negative
+	ZoneCacheTTL int `json:"zoneCacheTTL"`
+	// +optional. Use the last zone found for a name through the same
+	// nameservers, with a warning, when looking it up again fails
+	UseLastKnownZoneOnError bool `json:"useLastKnownZoneOnError"`
+	// +optional. Number of nameservers, among the first nameservers, that
+	// must each find the same zone, to catch split-horizon setups. The zone
+	// is not cross-checked by default
+	ZoneCrossCheck int `json:"zoneCrossCheck"`
+	// +optional. Resolvers queried to find the zones and check the
+	// propagation, as host:port, e.g. in air-gapped clusters. The public
+	// resolvers are queried by default
+	Nameservers []string `json:"nameservers"`
+	// +optional. Number of zone lookups running at once at most, not limited
+	// by default
+	MaxConcurrentZoneLookups int `json:"maxConcurrentZoneLookups"`
+	// +optional. Strip the _acme-challenge label from the record name, for
+	// the delegations expecting the TXT record at the delegated name itself
+	StripChallengePrefix bool `json:"stripChallengePrefix"`
+	// +optional. Lowercase the record name and check that its labels and the
+	// full name fit the DNS limits before writing it to GoDaddy
+	ValidateRecordName bool `json:"validateRecordName"`
+	// +optional. Keep the internationalized domain names as is instead of
+	// converting them to punycode, they are rejected then
+	DisableIDNConversion bool `json:"disableIDNConversion"`
+	// +optional. TTL of the TXT record per zone, overriding TTL. The zones
+	// apply to their subdomains too, the longest matching one wins
+	ZoneTTLOverrides map[string]int `json:"zoneTTLOverrides"`
+	// +optional.  API request timeout in seconds, 30 by default
 	HttpTimeout int `json:"timeout"`
-	// +optional.  Maximum waiting time for DNS propagation
+	// +optional. Factor applied to the API request timeout when production is
+	// false, as OTE is often slower than production. 1 by default
+	OTETimeoutMultiplier float64 `json:"oteTimeoutMultiplier"`
+	// +optional. Block Present until the TXT record is served by the
+	// nameservers, for at most PropagationTimeout seconds
+	WaitForPropagation bool `json:"waitForPropagation"`
+	// +optional.  Maximum waiting time for DNS propagation when
+	// WaitForPropagation is set, 120 seconds by default
 	PropagationTimeout int `json:"propagationTimeout"`
+	// +optional. Address of the DNS server queried by the propagation check,
+	// e.g. an authoritative nameserver of the zone. The recursive nameservers
+	// are queried by default
+	PropagationResolver string `json:"propagationResolver"`
+	// +optional. Suffixes of the domains whose propagation is not checked,
+	// e.g. OTE or internal test domains that do not resolve publicly
+	SkipPropagationSuffixes []string `json:"skipPropagationSuffixes"`
 	// +optional. Time between DNS propagation check
 	PollingInterval int `json:"pollingInterval"`
-	// +optional. Interval between iteration
+	// +optional. Minimum time in seconds between two writes to GoDaddy,
+	// spacing out the challenges presented at once to avoid its rate limits
 	SequenceInterval int `json:"sequenceInterval"`
+	// +optional. Log a short hash of the challenge key instead of redacting it
+	LogKeyHash bool `json:"logKeyHash"`
+	// +optional. Number of times an update is retried when GoDaddy reports a conflict
+	ConflictRetries int `json:"conflictRetries"`
+	// +optional. Number of times a rate limited request or a request failing
+	// with a server error is retried, 3 by default
+	MaxRetries int `json:"maxRetries"`
+	// +optional. Backoff in milliseconds before retrying a request without
+	// Retry-After header, doubled after each attempt up to a minute
+	RetryBackoff int `json:"retryBackoff"`
+	// +optional. Time in seconds after which Present and CleanUp retry
+	// nothing anymore and return the last error, leaving the backoff to
+	// cert-manager. Not capped by default
+	MaxInternalRetryDuration int `json:"maxInternalRetryDuration"`
+	// +optional. Number of times a Secret read throttled by the apiserver is retried
+	KubeMaxRetries int `json:"kubeMaxRetries"`
+	// +optional. Backoff in milliseconds before retrying a throttled Secret read,
+	// doubled after each attempt up to a minute
+	KubeRetryBackoff int `json:"kubeRetryBackoff"`
+	// +optional. Period in seconds during which presenting the same challenge
+	// again is skipped. Disabled if 0
+	DedupeWindow int `json:"dedupeWindow"`
+	// +optional. Maximum length of the TXT record value, 255 by default
+	MaxValueLength int `json:"maxValueLength"`
+	// +optional. Number of values the TXT record may hold at most, not
+	// limited by default
+	RecordLimit int `json:"recordLimit"`
+	// +optional. Number of challenge records, across all the names, that may
+	// be presented and not cleaned up yet, as a guardrail against runaway
+	// issuance. Counted in memory since the webhook started, not limited by
+	// default
+	RecordQuota int `json:"recordQuota"`
+	// +optional. What to do when presenting would exceed recordLimit: error
+	// (default) or prune the values of the challenges the webhook cleaned up,
+	// failing if there are not enough of them
+	OnRecordLimitExceeded string `json:"onRecordLimitExceeded"`
+	// +optional. Sort the TXT records by value when writing them
+	SortRecords bool `json:"sortRecords"`
+	// +optional. Compare the TXT values without their surrounding quotes, so
+	// that a quoted value stored by GoDaddy matches the challenge key
+	IgnoreQuotes bool `json:"ignoreQuotes"`
+	// +optional. Send PUT and DELETE requests as POST with a X-HTTP-Method-Override header
+	MethodOverride bool `json:"methodOverride"`
+	// +optional. Do not follow the redirects of the GoDaddy API
+	DisableRedirects bool `json:"disableRedirects"`
+	// +optional. Force HTTP/1.1 for the requests sent to GoDaddy
+	DisableHTTP2 bool `json:"disableHTTP2"`
+	// +optional. URL of the proxy the requests to GoDaddy go through, e.g.
+	// http://proxy.internal:3128, instead of the proxy of HTTPS_PROXY. The
+	// hosts of NO_PROXY and localhost are still reached directly
+	ProxyURL string `json:"proxyURL"`
+	// +optional. Check that the GoDaddy API is reachable before each
+	// operation, to fail fast on connectivity issues
+	PreflightReachability bool `json:"preflightReachability"`
+	// +optional. Check that the zone is delegated to the GoDaddy nameservers
+	// before presenting, to fail fast on domains hosted elsewhere
+	VerifyDelegation bool `json:"verifyDelegation"`
+	// +optional. Never send chunked request bodies, for proxies requiring a
+	// Content-Length
+	DisableChunked bool `json:"disableChunked"`
+	// +optional. Version of the schema of the records sent to GoDaddy: v1
+	// (default) or v2, which leaves out the type and name given by the path
+	BodySchema string `json:"bodySchema"`
+	// +optional. JSON fields of the records only sent to the OTE environment,
+	// e.g. fields production does not accept yet
+	OTEOnlyFields []string `json:"oteOnlyFields"`
+	// +optional. JSON fields of the records only sent to the production
+	// environment
+	ProductionOnlyFields []string `json:"productionOnlyFields"`
+	// +optional. Number of records read per request, from 1 to 500. All the
+	// records are read at once by default
+	PageSize int `json:"pageSize"`
+	// +optional. Fail on fields of the GoDaddy records unknown to the webhook,
+	// to catch API changes early when testing
+	StrictDecoding bool `json:"strictDecoding"`
+	// +optional. Fail writes answered with a successful status but an error in the body
+	CheckResponseBody bool `json:"checkResponseBody"`
+	// +optional. Log at startup the domains the credentials of the
+	// environment can manage, only applies to the configuration of the
+	// environment
+	LogManagedDomains bool `json:"logManagedDomains"`
+	// +optional. Log the request id and rate limit headers of the GoDaddy
+	// responses at verbosity 2
+	LogResponseHeaders bool `json:"logResponseHeaders"`
+	// +optional. Log the TXT records as JSON before changing them, their
+	// values redacted or hashed like the challenge key
+	SnapshotBeforeWrite bool `json:"snapshotBeforeWrite"`
+	// +optional. Look for a CNAME record at the name of the challenge before
+	// writing, instead of only once GoDaddy refused the TXT record
+	CheckCNAMEBeforeWrite bool `json:"checkCNAMEBeforeWrite"`
+	// +optional. Level of the log emitted when there is nothing to clean up:
+	// debug (default), info or warning
+	CleanupNoopLogLevel string `json:"cleanupNoopLogLevel"`
+	// +optional. Header used to send GoDaddy an ID correlating the requests of an operation
+	CorrelationHeader string `json:"correlationHeader"`
+	// +optional. Remove the keys of the concurrent cleanups of a record name
+	// with a single update
+	CoalesceCleanup bool `json:"coalesceCleanup"`
+	// +optional. Read the TXT record back after presenting it until GoDaddy
+	// serves it
+	VerifyWrite bool `json:"verifyWrite"`
+	// +optional. Number of read-backs of verifyWrite, 3 by default
+	VerifyAttempts int `json:"verifyAttempts"`
+	// +optional. Time in seconds between the read-backs of verifyWrite, 1 by
+	// default
+	VerifyInterval int `json:"verifyInterval"`
+	// +optional. Also remove the values of the challenges of the webhook that
+	// were left over: the values it cleaned up, and the values it presented
+	// more than OrphanAge seconds ago and never cleaned up. The values of the
+	// other replicas and issuers are never removed. The records are not
+	// matched by TTL or name, which cannot tell them from the live
+	// challenges of others: only the values remembered in memory since the
+	// webhook started are removed, nothing left over before a restart
+	RemoveOrphans bool `json:"removeOrphans"`
+	// +optional. Time in seconds after which removeOrphans removes a challenge
+	// presented and never cleaned up, 3600 by default
+	OrphanAge int `json:"orphanAge"`
+	// +optional. Write the records of a zone one operation at a time
+	SerializeZones bool `json:"serializeZones"`
+	// +optional. Watch removed records and remove them again if they reappear
+	AggressiveCleanup bool `json:"aggressiveCleanup"`
+	// +optional. Period in seconds during which removed records are watched,
+	// 10 by default
+	AggressiveCleanupWindow int `json:"aggressiveCleanupWindow"`
+	// +optional. Log the outcome of each Present and CleanUp as a JSON line
+	// instead of key/value pairs
+	StructuredLogs bool `json:"structuredLogs"`
+	// +optional. Log the duration of Present and CleanUp and of their phases
+	LogLatency bool `json:"logLatency"`
+	// +optional. Log the duration of each read of a Kubernetes Secret, at
+	// LatencyVerbosity, to diagnose a slow apiserver
+	LogSecretReads bool `json:"logSecretReads"`
+	// +optional. Verbosity of the latency logs, 0 by default
+	LatencyVerbosity int `json:"latencyVerbosity"`
+
+	// correlationID identifies the current operation when CorrelationHeader is set
+	correlationID string
+	// ctx cancels the requests of the current operation once the webhook stops
+	ctx context.Context
+	// retryDeadline is the time after which the current operation retries
+	// nothing anymore, when MaxInternalRetryDuration is set
+	retryDeadline time.Time
+	// latency times the current operation when LogLatency is set
+	latency *latencyLog
+	// operation records the current operation when StructuredLogs is set
+	operation *operationLog
+	// envAPIKey and envAPISecret are the credentials configured by the
+	// environment, used when no secret is referenced
+	envAPIKey    string
+	envAPISecret string
+	// envSecretNamespace is the namespace of the referenced secrets without
+	// namespace configured by the environment, the namespace of the challenge
+	// is used if empty
+	envSecretNamespace string
+}
+
+const (
+	defaultKubeMaxRetries   = 5
+	defaultKubeRetryBackoff = time.Second
+	// defaultMaxValueLength is the length of the longest TXT character string
+	defaultMaxValueLength = 255
+	// minTTL is the lowest TTL accepted by GoDaddy
+	minTTL = 600
+	// preflightTimeout bounds the reachability check of preflightReachability
+	preflightTimeout = 2 * time.Second
+	// maxPageSize is the largest number of records read per request
+	maxPageSize = 500
+)
+
+// findZoneByFqdn is replaced in tests to avoid DNS lookups.
+var findZoneByFqdn = util.FindZoneByFqdn
+
+// secretKeySelector references a key of a Secret. Unless a namespace is
+// given, the Secret lives in the namespace of the challenge resource.
+type secretKeySelector struct {
+	certmgrv1.SecretKeySelector `json:",inline"`
+
+	// +optional. Namespace of the Secret
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Values of CredentialPrecedence.
+const (
+	credentialPrecedenceSecret = "secret"
+	credentialPrecedenceInline = "inline"
+)
+
+// credentialRefs references the API key and secret of a GoDaddy credential.
+type credentialRefs struct {
+	APIKeyRef    secretKeySelector `json:"apiKeyRef"`
+	APISecretRef secretKeySelector `json:"apiSecretRef"`
+}
+
+// Keys of the API key and secret in the Secret of credentialsSecretRef by
+// default, as used by many Helm charts.
+const (
+	defaultAPIKeyKey    = "api-key"
+	defaultAPISecretKey = "api-secret"
+)
+
+// credentialsSecretRef references a Secret holding both the API key and
+// secret. Unless a namespace is given, the Secret lives in the namespace of
+// the challenge resource.
+type credentialsSecretRef struct {
+	certmgrv1.LocalObjectReference `json:",inline"`
+
+	// +optional. Namespace of the Secret
+	Namespace string `json:"namespace,omitempty"`
+	// +optional. Key of the API key, api-key by default
+	APIKeyKey string `json:"apiKeyKey,omitempty"`
+	// +optional. Key of the API secret, api-secret by default
+	APISecretKey string `json:"apiSecretKey,omitempty"`
+}
+
+// refs returns the references of the API key and secret held by the Secret.
+func (r credentialsSecretRef) refs() credentialRefs {
+	selector := func(key, defaultKey string) secretKeySelector {
+		if key == "" {
+			key = defaultKey
+		}
+		return secretKeySelector{
+			SecretKeySelector: certmgrv1.SecretKeySelector{LocalObjectReference: r.LocalObjectReference, Key: key},
+			Namespace:         r.Namespace,
+		}
+	}
+	return credentialRefs{
+		APIKeyRef:    selector(r.APIKeyKey, defaultAPIKeyKey),
+		APISecretRef: selector(r.APISecretKey, defaultAPISecretKey),
+	}
+}
+
+// credentials returns the credentials of cfg in the order they are tried.
+func (cfg *godaddyDNSProviderConfig) credentials() []credentialRefs {
+	creds := []credentialRefs{}
+	if cfg.APIKeyRef.LocalObjectReference.Name != "" || cfg.APISecretRef.LocalObjectReference.Name != "" {
+		creds = append(creds, credentialRefs{APIKeyRef: cfg.APIKeyRef, APISecretRef: cfg.APISecretRef})
+	}
+	if cfg.CredentialsSecretRef.Name != "" {
+		creds = append(creds, cfg.CredentialsSecretRef.refs())
+	}
+	return append(creds, cfg.Credentials...)
 }
 
 func (c *godaddyDNSSolver) validate(cfg *godaddyDNSProviderConfig) error {
 	// Try to load the API key
-	if cfg.APIKeyRef.LocalObjectReference.Name == "" || cfg.APISecretRef.LocalObjectReference.Name == "" {
+	if (cfg.AuthAPIKey == "") != (cfg.AuthAPISecret == "") {
+		return errors.New("authApiKey and authApiSecret must be set together")
+	}
+	switch cfg.CredentialPrecedence {
+	case "", credentialPrecedenceSecret, credentialPrecedenceInline:
+	default:
+		return fmt.Errorf("invalid credentialPrecedence %q, must be %s or %s", cfg.CredentialPrecedence, credentialPrecedenceSecret, credentialPrecedenceInline)
+	}
+	if cfg.CredentialsSecretRef.Name != "" && (cfg.APIKeyRef.LocalObjectReference.Name != "" || cfg.APISecretRef.LocalObjectReference.Name != "") {
+		return errors.New("credentialsSecretRef cannot be set along with apiKeyRef and apiSecretRef")
+	}
+	creds := cfg.credentials()
+	if len(creds) == 0 && cfg.AuthAPIKey == "" && (cfg.envAPIKey == "" || cfg.envAPISecret == "") {
 		return errors.New("API token field were not provided as no Kubernetes Secret exists !")
 	}
+	// The refs of the top-level apiKeyRef and apiSecretRef, or of
+	// credentialsSecretRef, come first
+	topLevel := len(creds) - len(cfg.Credentials)
+	for i, cred := range creds {
+		if cred.APIKeyRef.LocalObjectReference.Name == "" || cred.APISecretRef.LocalObjectReference.Name == "" {
+			return errors.New("API token field were not provided as no Kubernetes Secret exists !")
+		}
+		field := ""
+		if i >= topLevel {
+			field = fmt.Sprintf("credentials[%d].", i-topLevel)
+		}
+		if cred.APIKeyRef.Key == "" {
+			return fmt.Errorf("%sapiKeyRef.key is required, it names the entry of secret %q holding the API key", field, cred.APIKeyRef.LocalObjectReference.Name)
+		}
+		if cred.APISecretRef.Key == "" {
+			return fmt.Errorf("%sapiSecretRef.key is required, it names the entry of secret %q holding the API secret", field, cred.APISecretRef.LocalObjectReference.Name)
+		}
+	}
+	if cfg.TTL < 0 {
+		return fmt.Errorf("invalid ttl %d, must not be negative", cfg.TTL)
+	}
+	for zone, ttl := range cfg.ZoneTTLOverrides {
+		if ttl < 0 {
+			return fmt.Errorf("invalid ttl %d for zone %q, must not be negative", ttl, zone)
+		}
+	}
+	switch cfg.CleanupNoopLogLevel {
+	case "", "debug", "info", "warning":
+	default:
+		return fmt.Errorf("invalid cleanupNoopLogLevel %q, must be one of debug, info or warning", cfg.CleanupNoopLogLevel)
+	}
+	switch cfg.BodySchema {
+	case "", godaddy.SchemaV1, godaddy.SchemaV2:
+	default:
+		return fmt.Errorf("invalid bodySchema %q, must be %s or %s", cfg.BodySchema, godaddy.SchemaV1, godaddy.SchemaV2)
+	}
+	switch cfg.OnRecordLimitExceeded {
+	case "", "error", "prune":
+	default:
+		return fmt.Errorf("invalid onRecordLimitExceeded %q, must be error or prune", cfg.OnRecordLimitExceeded)
+	}
+	for _, server := range cfg.Nameservers {
+		if host, port, err := net.SplitHostPort(server); err != nil || host == "" || port == "" {
+			return fmt.Errorf("invalid nameserver %q, must be host:port", server)
+		}
+	}
+	if cfg.OTETimeoutMultiplier < 0 {
+		return fmt.Errorf("invalid oteTimeoutMultiplier %v, must not be negative", cfg.OTETimeoutMultiplier)
+	}
+	if cfg.ZoneCrossCheck < 0 {
+		return fmt.Errorf("invalid zoneCrossCheck %d, must not be negative", cfg.ZoneCrossCheck)
+	}
+	if cfg.PageSize < 0 || cfg.PageSize > maxPageSize {
+		return fmt.Errorf("invalid pageSize %d, must be between 0 and %d (0 disables paging)", cfg.PageSize, maxPageSize)
+	}
+	if cfg.ShopperID != "" && !isNumeric(cfg.ShopperID) {
+		return fmt.Errorf("invalid shopperId %q, must be numeric", cfg.ShopperID)
+	}
+	if cfg.TestOnlyKey != "" && cfg.Production {
+		return errors.New("testOnlyKey is for testing only and cannot be used in production")
+	}
+	if cfg.ProxyURL != "" {
+		if err := validateProxyURL(cfg.ProxyURL); err != nil {
+			return err
+		}
+	}
+	if cfg.BaseURL != "" {
+		if err := validateAPIBaseURL(cfg.BaseURL); err != nil {
+			return err
+		}
+		if cfg.AutoDetectEnv {
+			return errors.New("autoDetectEnv cannot be used along with apiBaseURL")
+		}
+	}
 	return nil
 }
 
+// validateAPIBaseURL checks that the credentials are never sent in plaintext:
+// plain http is only accepted for local test servers.
+func validateAPIBaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid apiBaseURL %q: %v", rawURL, err)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !isLocalHost(u.Hostname()) {
+			return fmt.Errorf("invalid apiBaseURL %q: http is only allowed for localhost, use https", rawURL)
+		}
+	default:
+		return fmt.Errorf("invalid apiBaseURL %q: scheme must be https", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid apiBaseURL %q: missing host", rawURL)
+	}
+	// The paths of the API are appended to the URL, and the credentials are
+	// only ever sent as the Authorization header
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid apiBaseURL %q: must not hold credentials, a query or a fragment", rawURL)
+	}
+	return nil
+}
+
+// isNumeric reports whether s only holds decimal digits.
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // Name is used as the name for this DNS solver when referencing it on the ACME
 // Issuer resource.
 // This should be unique **within the group name**, i.e. you can have two
@@ -114,6 +694,9 @@ func (c *godaddyDNSSolver) validate(cfg *godaddyDNSProviderConfig) error {
 // within a single webhook deployment**.
 // For example, `cloudflare` may be used as the name of a solver.
 func (c *godaddyDNSSolver) Name() string {
+	if c.name != "" {
+		return c.name
+	}
 	return providerName
 }
 
@@ -122,49 +705,244 @@ func (c *godaddyDNSSolver) Name() string {
 // OTE environment: https://api.ote-godaddy.com
 // PRODUCTION environment: https://api.godaddy.com
 func (c *godaddyDNSSolver) apiURL(cfg godaddyDNSProviderConfig) string {
-	baseURL := "https://api.ote-godaddy.com"
+	if cfg.BaseURL != "" {
+		return strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	return godaddy.BaseURL(cfg.Production)
+}
+
+// excludedFields returns the fields of the records the targeted environment
+// does not accept.
+func excludedFields(cfg godaddyDNSProviderConfig) []string {
 	if cfg.Production {
-		baseURL = "https://api.godaddy.com"
+		return cfg.OTEOnlyFields
+	}
+	return cfg.ProductionOnlyFields
+}
+
+// httpTimeout returns the timeout of the requests to GoDaddy, scaled by
+// OTETimeoutMultiplier when targeting OTE.
+func httpTimeout(cfg godaddyDNSProviderConfig) time.Duration {
+	timeout := godaddy.DefaultTimeout
+	if cfg.HttpTimeout > 0 {
+		timeout = time.Duration(cfg.HttpTimeout) * time.Second
+	}
+	if !cfg.Production && cfg.OTETimeoutMultiplier > 0 {
+		timeout = time.Duration(float64(timeout) * cfg.OTETimeoutMultiplier)
+	}
+	return timeout
+}
+
+// newClient returns a client of the GoDaddy API configured by cfg.
+func (c *godaddyDNSSolver) newClient(cfg godaddyDNSProviderConfig) *godaddy.Client {
+	return &godaddy.Client{
+		BaseURL:            c.apiURL(cfg),
+		APIKey:             cfg.AuthAPIKey,
+		APISecret:          cfg.AuthAPISecret,
+		Timeout:            httpTimeout(cfg),
+		MaxRetries:         cfg.MaxRetries,
+		RetryBackoff:       time.Duration(cfg.RetryBackoff) * time.Millisecond,
+		ConflictRetries:    cfg.ConflictRetries,
+		MaxValues:          cfg.RecordLimit,
+		PruneOnLimit:       cfg.OnRecordLimitExceeded == "prune",
+		Stale:              c.stale.has,
+		SortRecords:        cfg.SortRecords,
+		IgnoreQuotes:       cfg.IgnoreQuotes,
+		MethodOverride:     cfg.MethodOverride,
+		DisableRedirects:   cfg.DisableRedirects,
+		Transport:          c.transports.get(cfg),
+		DisableChunked:     cfg.DisableChunked,
+		BodySchema:         cfg.BodySchema,
+		ExcludeFields:      excludedFields(cfg),
+		PageSize:           cfg.PageSize,
+		StrictDecoding:     cfg.StrictDecoding,
+		CheckResponseBody:  cfg.CheckResponseBody,
+		LogResponseHeaders: cfg.LogResponseHeaders,
+		CorrelationHeader:  cfg.CorrelationHeader,
+		ShopperID:          cfg.ShopperID,
+		CorrelationID:      cfg.correlationID,
+		RetryDeadline:      cfg.retryDeadline,
+		Context:            cfg.ctx,
+		Trace: func(method string) func(int) {
+			done := tracePhase(cfg, method)
+			observe := observeRequest(method)
+			return func(statusCode int) {
+				done()
+				observe(statusCode)
+				if cfg.operation != nil {
+					cfg.operation.Status = statusCode
+				}
+			}
+		},
+		LogData: func(data string) string {
+			return keyForLog(cfg, data)
+		},
+		Retried: func() {
+			if cfg.operation != nil {
+				cfg.operation.Retries++
+			}
+		},
 	}
-	return baseURL
 }
 
+// serialize waits until no other operation writes to zone if SerializeZones
+// is set, and returns the function ending the operation.
+func (c *godaddyDNSSolver) serialize(cfg godaddyDNSProviderConfig, zone string) func() {
+	if !cfg.SerializeZones {
+		return func() {}
+	}
+	return c.zones.lock(zone)
+}
+
+// preflight checks that the GoDaddy API is reachable if PreflightReachability
+// is set.
+func (c *godaddyDNSSolver) preflight(cfg godaddyDNSProviderConfig) error {
+	if !cfg.PreflightReachability {
+		return nil
+	}
+	return c.newClient(cfg).Ping(preflightTimeout)
+}
+
+// extractApiTokenFromSecret sets the credentials of cfg. When both secrets are
+// referenced and inline credentials are set, CredentialPrecedence picks the
+// ones used, the secrets by default. GODADDY_API_KEY and GODADDY_API_SECRET
+// are only used when there are neither.
 func (c *godaddyDNSSolver) extractApiTokenFromSecret(cfg *godaddyDNSProviderConfig, ch *v1alpha1.ChallengeRequest) error {
-	keySec, err := c.client.CoreV1().
-		Secrets(ch.ResourceNamespace).
-		Get(cfg.APIKeyRef.LocalObjectReference.Name, metaV1.GetOptions{})
+	creds := cfg.credentials()
+	inline := cfg.AuthAPIKey != ""
+	switch {
+	case len(creds) == 0 && inline:
+		return nil
+	case len(creds) == 0:
+		cfg.AuthAPIKey, cfg.AuthAPISecret = cfg.envAPIKey, cfg.envAPISecret
+		return nil
+	case inline && cfg.CredentialPrecedence == credentialPrecedenceInline:
+		klog.Warningf("Both secrets and inline credentials are configured, using the inline credentials")
+		// Rotating must not fall back to the ignored secrets either
+		cfg.APIKeyRef, cfg.APISecretRef, cfg.Credentials = secretKeySelector{}, secretKeySelector{}, nil
+		cfg.CredentialsSecretRef = credentialsSecretRef{}
+		return nil
+	case inline:
+		klog.Warningf("Both secrets and inline credentials are configured, using the secrets")
+	}
+	return c.readCredentials(cfg, creds[0], ch)
+}
+
+// readCredentials reads the API key and secret referenced by cred into cfg.
+func (c *godaddyDNSSolver) readCredentials(cfg *godaddyDNSProviderConfig, cred credentialRefs, ch *v1alpha1.ChallengeRequest) error {
+	key, secret, err := c.credentialSource().Fetch(*cfg, cred, ch.ResourceNamespace)
 	if err != nil {
 		return err
 	}
+	cfg.AuthAPIKey, cfg.AuthAPISecret = key, secret
+	return nil
+}
 
-	keySecBytes, ok := keySec.Data[cfg.APIKeyRef.Key]
-	if !ok {
-		return fmt.Errorf("Key %q not found in secret \"%s/%s\"",
-			cfg.APIKeyRef.Key,
-			cfg.APIKeyRef.LocalObjectReference.Name,
-			ch.ResourceNamespace)
+// withCredentials runs op with a client authenticated by the credentials read
+// into cfg. While GoDaddy rejects them, op is run again with the next
+// credentials configured, so that keys can be rotated without downtime.
+func (c *godaddyDNSSolver) withCredentials(cfg *godaddyDNSProviderConfig, ch *v1alpha1.ChallengeRequest, op func(client *godaddy.Client) error) error {
+	creds := cfg.credentials()
+	for i := 0; ; i++ {
+		err := c.inEnvironment(cfg, op)
+		if !godaddy.IsUnauthorized(err) || i+1 >= len(creds) {
+			return environmentHint(*cfg, err)
+		}
+		klog.Warningf("GoDaddy rejected credentials %d of %d, trying the next ones", i+1, len(creds))
+		if err := c.readCredentials(cfg, creds[i+1], ch); err != nil {
+			return err
+		}
 	}
+}
 
-	cfg.AuthAPIKey = string(keySecBytes)
+// readSecretKey returns the value referenced by ref. The Secret is read from
+// the namespace of the reference if set, from WEBHOOK_SECRET_NAMESPACE if set,
+// or from defaultNamespace otherwise.
+func (c *godaddyDNSSolver) readSecretKey(cfg godaddyDNSProviderConfig, ref secretKeySelector, defaultNamespace string) (string, error) {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cfg.envSecretNamespace
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
 
-	secSec, err := c.client.CoreV1().
-		Secrets(ch.ResourceNamespace).
-		Get(cfg.APISecretRef.LocalObjectReference.Name, metaV1.GetOptions{})
+	sec, err := c.getSecret(cfg, namespace, ref.LocalObjectReference.Name)
+	if apierrors.IsForbidden(err) {
+		return "", fmt.Errorf("not allowed to read secret \"%s/%s\", check the RBAC permissions of the webhook: %v",
+			namespace,
+			ref.LocalObjectReference.Name,
+			err)
+	}
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	secSecBytes, ok := secSec.Data[cfg.APISecretRef.Key]
+	secBytes, ok := sec.Data[ref.Key]
 	if !ok {
-		return fmt.Errorf("Key %q not found in secret \"%s/%s\"",
-			cfg.APISecretRef.Key,
-			cfg.APISecretRef.LocalObjectReference.Name,
-			ch.ResourceNamespace)
+		return "", &missingKeyError{Key: ref.Key, Namespace: namespace, Name: ref.LocalObjectReference.Name}
 	}
 
-	cfg.AuthAPISecret = string(secSecBytes)
+	return string(secBytes), nil
+}
 
-	return nil
+// missingKeyError is returned when a referenced secret lacks the key holding
+// a credential.
+type missingKeyError struct {
+	Key       string
+	Namespace string
+	Name      string
+}
+
+func (e *missingKeyError) Error() string {
+	return fmt.Sprintf("Key %q not found in secret \"%s/%s\"", e.Key, e.Namespace, e.Name)
+}
+
+// isMissingCredentials reports whether err tells that the referenced secret or
+// its key do not exist.
+func isMissingCredentials(err error) bool {
+	var missingKey *missingKeyError
+	return apierrors.IsNotFound(err) || errors.As(err, &missingKey)
+}
+
+// getSecret reads a Secret, retrying with backoff while the apiserver
+// throttles the requests. The delay suggested by the apiserver is honored,
+// the backoff configured by KubeRetryBackoff is used otherwise. Every attempt
+// still goes through the rate limiter of the clientset.
+func (c *godaddyDNSSolver) getSecret(cfg godaddyDNSProviderConfig, namespace string, name string) (*corev1.Secret, error) {
+	maxRetries := cfg.KubeMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultKubeMaxRetries
+	}
+	backoff := defaultKubeRetryBackoff
+	if cfg.KubeRetryBackoff > 0 {
+		backoff = time.Duration(cfg.KubeRetryBackoff) * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := now()
+		sec, err := c.kubeClient().CoreV1().
+			Secrets(namespace).
+			Get(name, metaV1.GetOptions{})
+		if cfg.LogSecretReads {
+			klog.V(klog.Level(cfg.LatencyVerbosity)).Infof("Reading secret \"%s/%s\" took %v", namespace, name, now().Sub(start))
+		}
+		if !apierrors.IsTooManyRequests(err) || attempt >= maxRetries {
+			return sec, err
+		}
+
+		delay := godaddy.Backoff(backoff, attempt)
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			delay = time.Duration(seconds) * time.Second
+		}
+		if !canRetry(cfg, delay) {
+			return sec, err
+		}
+		klog.Warningf("Kubernetes apiserver throttled the read of secret \"%s/%s\", retrying in %v", namespace, name, delay)
+		if err := sleep(cfg.context(), delay); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // Present is responsible for actually presenting the DNS record with the
@@ -172,16 +950,67 @@ func (c *godaddyDNSSolver) extractApiTokenFromSecret(cfg *godaddyDNSProviderConf
 // This method should tolerate being called multiple times with the same value.
 // cert-manager itself will later perform a self check to ensure that the
 // solver has correctly configured the DNS provider.
-func (c *godaddyDNSSolver) Present(ch *v1alpha1.ChallengeRequest) error {
-	cfg, err := loadConfig(ch.Config)
+// Nothing is written when the TXT record already holds the key, and the key is
+// merged with the values of the other challenges otherwise.
+func (c *godaddyDNSSolver) Present(ch *v1alpha1.ChallengeRequest) (err error) {
+	cfg, err := loadConfig(ch.Config, c.defaults)
 	if err != nil {
 		return err
 	}
 
+	// GoDaddy only knows the ASCII form of internationalized domain names
+	if ch, err = normalizeIDN(cfg, ch); err != nil {
+		return err
+	}
+	if err := validateFQDN(ch.ResolvedFQDN); err != nil {
+		return err
+	}
+	if ch, err = withRegistrableZone(cfg, ch); err != nil {
+		return err
+	}
+
 	// Verify if the config contains the required parameters such as SecretRef
 	if err := c.validate(&cfg); err != nil {
 		return err
 	}
+	ch = withTestOnlyKey(cfg, ch)
+	setRetryDeadline(&cfg)
+	cfg.ctx = c.context()
+
+	if err := validateValue(cfg, ch.Key); err != nil {
+		return err
+	}
+
+	defer startLatencyLog(&cfg, "Presenting", ch.ResolvedFQDN)()
+	logOperation := startOperationLog(&cfg, "present", ch.Key)
+	defer func() { logOperation(err) }()
+
+	// A value presented again is no longer left over
+	c.stale.remove(ch.Key)
+
+	// Skip the challenges that were just presented, e.g. when cert-manager
+	// retries while an earlier attempt eventually succeeded
+	key := idempotencyKey(ch)
+	window := time.Duration(cfg.DedupeWindow) * time.Second
+	if window > 0 && c.presented.seen(key, window) {
+		klog.Infof("TXT record for %q was presented less than %v ago, skipping", ch.ResolvedFQDN, window)
+		setOutcome(cfg, outcomeSkipped)
+		return nil
+	}
+
+	if cfg.RecordQuota > 0 {
+		reserved, quotaErr := c.quota.reserve(key, cfg.RecordQuota)
+		if quotaErr != nil {
+			return quotaErr
+		}
+		if reserved {
+			defer func() {
+				if err != nil {
+					c.quota.release(key)
+				}
+			}()
+		}
+	}
 
 	// Extract the Godaddy Api and Secret from the K8s Secret
 	// and assign it the AuthAPIKey and AuthAPISecret of the Config
@@ -189,25 +1018,58 @@ func (c *godaddyDNSSolver) Present(ch *v1alpha1.ChallengeRequest) error {
 		return err
 	}
 
-	baseURL := c.apiURL(cfg)
+	dnsZone, err := c.getZone(cfg, ch.ResolvedZone)
+	if err != nil {
+		return err
+	}
+	// The name is relative to the zone written to, which may differ from the
+	// zone cert-manager resolved
+	recordName := c.challengeRecordName(cfg, ch.ResolvedFQDN, dnsZone)
+	if recordName, err = checkRecordName(cfg, recordName, dnsZone); err != nil {
+		return err
+	}
+
+	if err := setCorrelationID(&cfg); err != nil {
+		return err
+	}
 
-	recordName := c.extractRecordName(ch.ResolvedFQDN, ch.ResolvedZone)
+	logRecord("Presenting", cfg, recordName, dnsZone, ch.Key)
+	recordOperation(cfg, dnsZone, recordName)
+
+	if err := c.preflight(cfg); err != nil {
+		return correlate(cfg, err)
+	}
+	if err := verifyDelegation(cfg, dnsZone); err != nil {
+		return correlate(cfg, err)
+	}
 
-	dnsZone, err := c.getZone(ch.ResolvedZone)
+	if err := c.sequence(cfg); err != nil {
+		return correlate(cfg, err)
+	}
+	unlock := c.serialize(cfg, dnsZone)
+	err = c.withCredentials(&cfg, ch, func(client *godaddy.Client) error {
+		if _, err := reconcileTXT(cfg, client, dnsZone, recordName, []string{ch.Key}, nil); err != nil {
+			return err
+		}
+		c.live.add(ch.Key)
+		if cfg.VerifyWrite {
+			return verifyWrite(cfg, client, dnsZone, recordName, ch.Key)
+		}
+		return nil
+	})
+	unlock()
 	if err != nil {
-		return err
+		return correlate(cfg, err)
 	}
 
-	rec := []DNSRecord{
-		{
-			Type: "TXT",
-			Name: recordName,
-			Data: ch.Key,
-			TTL:  cfg.TTL,
-		},
+	if err := waitForPropagation(cfg, ch.ResolvedFQDN, ch.Key); err != nil {
+		return correlate(cfg, err)
 	}
 
-	return c.updateRecords(cfg, baseURL, rec, dnsZone, recordName)
+	if window > 0 {
+		c.presented.add(key)
+	}
+	return nil
 }
 
 // CleanUp should delete the relevant TXT record from the DNS provider console.
@@ -216,41 +1078,140 @@ func (c *godaddyDNSSolver) Present(ch *v1alpha1.ChallengeRequest) error {
 // value provided on the ChallengeRequest should be cleaned up.
 // This is in order to facilitate multiple DNS validations for the same domain
 // concurrently.
-func (c *godaddyDNSSolver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
-	cfg, err := loadConfig(ch.Config)
+func (c *godaddyDNSSolver) CleanUp(ch *v1alpha1.ChallengeRequest) (err error) {
+	cfg, err := loadConfig(ch.Config, c.defaults)
 	if err != nil {
 		return err
 	}
 
+	// GoDaddy only knows the ASCII form of internationalized domain names
+	if ch, err = normalizeIDN(cfg, ch); err != nil {
+		return err
+	}
+	if err := validateFQDN(ch.ResolvedFQDN); err != nil {
+		return err
+	}
+	if ch, err = withRegistrableZone(cfg, ch); err != nil {
+		return err
+	}
+
 	// Verify if the config contains the required parameters such as SecretRef
 	if err := c.validate(&cfg); err != nil {
 		return err
 	}
+	ch = withTestOnlyKey(cfg, ch)
+	setRetryDeadline(&cfg)
+	cfg.ctx = c.context()
+
+	// Presenting the challenge again must not be skipped once cleaned up
+	c.presented.remove(idempotencyKey(ch))
+	// The value is left over if the cleanup fails, and may then be pruned
+	c.stale.add(ch.Key)
+	c.live.remove(ch.Key)
+	// The record no longer counts against the quota once cleaned up
+	defer func() {
+		if err == nil {
+			c.quota.release(idempotencyKey(ch))
+		}
+	}()
+
+	defer startLatencyLog(&cfg, "Cleaning up", ch.ResolvedFQDN)()
+	logOperation := startOperationLog(&cfg, "cleanup", ch.Key)
+	defer func() { logOperation(err) }()
 
 	// Extract the Godaddy Api and Secret from the K8s Secret
 	// and assign it the AuthAPIKey and AuthAPISecret of the Config
 	if err := c.extractApiTokenFromSecret(&cfg, ch); err != nil {
+		// The credentials may have been rotated away before a late cleanup,
+		// which must not block the finalization of the challenge then
+		if cfg.IgnoreMissingCredentialsOnCleanup && isMissingCredentials(err) {
+			klog.Warningf("Skipping the cleanup of %q, its credentials are missing: %v", ch.ResolvedFQDN, err)
+			setOutcome(cfg, outcomeSkipped)
+			return nil
+		}
 		return err
 	}
 
-	baseURL := c.apiURL(cfg)
-
-	recordName := c.extractRecordName(ch.ResolvedFQDN, ch.ResolvedZone)
-
-	dnsZone, err := c.getZone(ch.ResolvedZone)
+	dnsZone, err := c.getZone(cfg, ch.ResolvedZone)
 	if err != nil {
 		return err
 	}
+	// The name is relative to the zone written to, which may differ from the
+	// zone cert-manager resolved
+	recordName := c.challengeRecordName(cfg, ch.ResolvedFQDN, dnsZone)
+	if recordName, err = checkRecordName(cfg, recordName, dnsZone); err != nil {
+		return err
+	}
 
-	rec := []DNSRecord{
-		{
-			Type: "TXT",
-			Name: recordName,
-			Data: "null",
-		},
+	if err := setCorrelationID(&cfg); err != nil {
+		return err
+	}
+
+	logRecord("Cleaning up", cfg, recordName, dnsZone, ch.Key)
+	recordOperation(cfg, dnsZone, recordName)
+
+	if err := c.preflight(cfg); err != nil {
+		return correlate(cfg, err)
 	}
 
-	return c.updateRecords(cfg, baseURL, rec, dnsZone, recordName)
+	if cfg.CoalesceCleanup {
+		// The batch is only shared by the cleanups using the same account
+		batchKey := strings.Join([]string{c.apiURL(cfg), cfg.AuthAPIKey, dnsZone, recordName}, "|")
+		var correlationID string
+		correlationID, err = c.cleanups.remove(cfg, batchKey, ch.Key, coalesceWindow, func(keys []string) error {
+			if err := c.sequence(cfg); err != nil {
+				return err
+			}
+			defer c.serialize(cfg, dnsZone)()
+			return c.withCredentials(&cfg, ch, func(client *godaddy.Client) error {
+				values, err := c.withOrphans(cfg, client, ch, dnsZone, recordName, keys)
+				if err != nil {
+					return err
+				}
+				if err := removeValues(cfg, client, dnsZone, recordName, values); err != nil {
+					return err
+				}
+				if cfg.AggressiveCleanup {
+					return ensureRemoved(cfg, client, dnsZone, recordName, keys)
+				}
+				return nil
+			})
+		})
+		// The requests carried the correlation ID of the cleanup which
+		// flushed the batch
+		if correlationID != cfg.correlationID {
+			if correlationID != "" {
+				klog.Infof("Cleanup of TXT record %q in zone %q coalesced with the one of correlation id %s", recordName, dnsZone, correlationID)
+			}
+			cfg.correlationID = correlationID
+		}
+		return correlate(cfg, err)
+	}
+
+	if err := c.sequence(cfg); err != nil {
+		return correlate(cfg, err)
+	}
+	defer c.serialize(cfg, dnsZone)()
+	err = c.withCredentials(&cfg, ch, func(client *godaddy.Client) error {
+		remove, err := c.withOrphans(cfg, client, ch, dnsZone, recordName, []string{ch.Key})
+		if err != nil {
+			return err
+		}
+
+		changed, err := reconcileTXT(cfg, client, dnsZone, recordName, nil, remove)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			logNoopCleanUp(cfg, recordName, dnsZone)
+			return nil
+		}
+		if cfg.AggressiveCleanup {
+			return ensureRemoved(cfg, client, dnsZone, recordName, []string{ch.Key})
+		}
+		return nil
+	})
+	return correlate(cfg, err)
 }
 
 // Initialize will be called when the webhook first starts.
@@ -263,19 +1224,67 @@ func (c *godaddyDNSSolver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
 // The stopCh can be used to handle early termination of the webhook, in cases
 // where a SIGTERM or similar signal is sent to the webhook process.
 func (c *godaddyDNSSolver) Initialize(kubeClientConfig *rest.Config, stopCh <-chan struct{}) error {
+	if kubeClientConfig == nil {
+		return fmt.Errorf("cannot initialize the %s solver: no Kubernetes client config provided", c.Name())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Initialize is idempotent, the solvers keep the client they got first
+	if c.client != nil {
+		return nil
+	}
+
 	cl, err := kubernetes.NewForConfig(kubeClientConfig)
 	if err != nil {
 		return err
 	}
 
 	c.client = cl
+	c.ctx = stopContext(stopCh)
+	if c.defaults.LogManagedDomains {
+		go c.logManagedDomains()
+	}
 	return nil
 }
 
+func (c *godaddyDNSSolver) kubeClient() kubernetes.Interface {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+// context returns the context of the operations of the solver, done once the
+// webhook stops.
+func (c *godaddyDNSSolver) context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// stopContext returns a context cancelled once stopCh is closed.
+func stopContext(stopCh <-chan struct{}) context.Context {
+	if stopCh == nil {
+		return context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	return ctx
+}
+
 // loadConfig is a small helper function that decodes JSON configuration into
-// the typed config struct.
-func loadConfig(cfgJSON *apiext.JSON) (godaddyDNSProviderConfig, error) {
-	cfg := godaddyDNSProviderConfig{}
+// the typed config struct, on top of the given defaults. The defaults are
+// shared by every challenge, so the configuration is decoded into a copy of
+// them holding its own slices and maps.
+func loadConfig(cfgJSON *apiext.JSON, defaults godaddyDNSProviderConfig) (godaddyDNSProviderConfig, error) {
+	cfg := defaults.clone()
 	// handle the 'base case' where no configuration has been provided
 	if cfgJSON == nil {
 		return cfg, nil
@@ -287,66 +1296,317 @@ func loadConfig(cfgJSON *apiext.JSON) (godaddyDNSProviderConfig, error) {
 	return cfg, nil
 }
 
-func (c *godaddyDNSSolver) updateRecords(cfg godaddyDNSProviderConfig, baseURL string, records []DNSRecord, domainZone string, recordName string) error {
-	body, err := json.Marshal(records)
-	if err != nil {
-		return err
+// clone returns a copy of cfg sharing none of its slices and maps, which
+// encoding/json would otherwise write into.
+func (cfg godaddyDNSProviderConfig) clone() godaddyDNSProviderConfig {
+	cloned := cfg
+	cloned.Credentials = append([]credentialRefs(nil), cfg.Credentials...)
+	cloned.KnownZoneSuffixes = append([]string(nil), cfg.KnownZoneSuffixes...)
+	cloned.Nameservers = append([]string(nil), cfg.Nameservers...)
+	cloned.SkipPropagationSuffixes = append([]string(nil), cfg.SkipPropagationSuffixes...)
+	cloned.OTEOnlyFields = append([]string(nil), cfg.OTEOnlyFields...)
+	cloned.ProductionOnlyFields = append([]string(nil), cfg.ProductionOnlyFields...)
+	if cfg.ZoneTTLOverrides != nil {
+		cloned.ZoneTTLOverrides = make(map[string]int, len(cfg.ZoneTTLOverrides))
+		for zone, ttl := range cfg.ZoneTTLOverrides {
+			cloned.ZoneTTLOverrides[zone] = ttl
+		}
 	}
+	return cloned
+}
 
-	var resp *http.Response
-	url := fmt.Sprintf("/v1/domains/%s/records/TXT/%s", domainZone, recordName)
-	resp, err = c.makeRequest(cfg, baseURL, http.MethodPut, url, bytes.NewReader(body))
-	if err != nil {
+// context returns the context of the operation of cfg, done once the webhook
+// stops.
+func (cfg godaddyDNSProviderConfig) context() context.Context {
+	if cfg.ctx == nil {
+		return context.Background()
+	}
+	return cfg.ctx
+}
+
+// logRecord logs an operation on the TXT record of a challenge. The challenge
+// key is never written as is: it is redacted, or replaced by a short hash when
+// LogKeyHash is set so that operators can correlate log lines.
+func logRecord(action string, cfg godaddyDNSProviderConfig, recordName string, domainZone string, key string) {
+	if cfg.correlationID != "" {
+		klog.Infof("%s TXT record %q in zone %q (key: %s, correlation id: %s)", action, recordName, domainZone, keyForLog(cfg, key), cfg.correlationID)
+		return
+	}
+	klog.Infof("%s TXT record %q in zone %q (key: %s)", action, recordName, domainZone, keyForLog(cfg, key))
+}
+
+func keyForLog(cfg godaddyDNSProviderConfig, key string) string {
+	if !cfg.LogKeyHash {
+		return "<redacted>"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// snapshotRecords logs the TXT records of recordName as JSON, so that their
+// state prior to a write can be recovered. Like the challenge key in the other
+// log lines, their values are redacted, or hashed when LogKeyHash is set.
+func snapshotRecords(cfg godaddyDNSProviderConfig, domainZone string, recordName string, records []godaddy.Record) error {
+	redacted := make([]godaddy.Record, len(records))
+	for i, r := range records {
+		r.Data = keyForLog(cfg, r.Data)
+		redacted[i] = r
+	}
+
+	var snapshot bytes.Buffer
+	enc := json.NewEncoder(&snapshot)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redacted); err != nil {
 		return err
 	}
+	klog.Infof("Snapshot of TXT record %q in zone %q before write: %s", recordName, domainZone, strings.TrimSuffix(snapshot.String(), "\n"))
+	return nil
+}
 
-	defer resp.Body.Close()
+// logNoopCleanUp logs that there is nothing to clean up, at the level set by
+// CleanupNoopLogLevel.
+func logNoopCleanUp(cfg godaddyDNSProviderConfig, recordName string, domainZone string) {
+	setOutcome(cfg, outcomeNoop)
+
+	const format = "Nothing to clean up for TXT record %q in zone %q"
+	switch cfg.CleanupNoopLogLevel {
+	case "warning":
+		klog.Warningf(format, recordName, domainZone)
+	case "info":
+		klog.Infof(format, recordName, domainZone)
+	default:
+		klog.V(4).Infof(format, recordName, domainZone)
+	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("could not create record %v; Status: %v; Body: %s", string(body), resp.StatusCode, string(bodyBytes))
+// setCorrelationID generates the ID sent in the correlation header for the
+// requests of the current operation, if such a header is configured.
+func setCorrelationID(cfg *godaddyDNSProviderConfig) error {
+	if cfg.CorrelationHeader == "" {
+		return nil
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Errorf("could not generate correlation id: %v", err)
 	}
+	// Format the random bytes as a version 4 UUID
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	cfg.correlationID = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 	return nil
 }
 
-func (c *godaddyDNSSolver) makeRequest(cfg godaddyDNSProviderConfig, baseURL string, method string, uri string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", baseURL, uri), body)
-	if err != nil {
-		return nil, err
+// setRetryDeadline bounds the retries of the operation of cfg to
+// MaxInternalRetryDuration seconds from now, if set.
+func setRetryDeadline(cfg *godaddyDNSProviderConfig) {
+	if cfg.MaxInternalRetryDuration > 0 {
+		cfg.retryDeadline = now().Add(time.Duration(cfg.MaxInternalRetryDuration) * time.Second)
 	}
+}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", pkgutil.CertManagerUserAgent)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("sso-key %s:%s", cfg.AuthAPIKey, cfg.AuthAPISecret))
+// canRetry reports whether a retry waiting for delay would start before the
+// retry deadline of the operation of cfg.
+func canRetry(cfg godaddyDNSProviderConfig, delay time.Duration) bool {
+	return cfg.retryDeadline.IsZero() || !now().Add(delay).After(cfg.retryDeadline)
+}
+
+// correlate adds the correlation ID of the operation to err.
+func correlate(cfg godaddyDNSProviderConfig, err error) error {
+	if err == nil || cfg.correlationID == "" {
+		return err
+	}
+	return fmt.Errorf("%w (correlation id: %s)", err, cfg.correlationID)
+}
 
-	client := http.Client{
-		Timeout: 30 * time.Second,
+// recordTTL returns the TTL of the TXT record presented in domainZone: the TTL
+// configured for the longest zone domainZone belongs to if any, TTL otherwise.
+// TTLs lower than what GoDaddy accepts are raised to its minimum, which is
+// also used when no TTL is set.
+func recordTTL(cfg godaddyDNSProviderConfig, domainZone string) int {
+	ttl := cfg.TTL
+	name := strings.ToLower(util.UnFqdn(domainZone))
+	matched, matchedLen := "", -1
+	for zone, zoneTTL := range cfg.ZoneTTLOverrides {
+		suffix := strings.ToLower(util.UnFqdn(zone))
+		if name != suffix && !strings.HasSuffix(name, "."+suffix) {
+			continue
+		}
+		// Spellings of the same zone are ordered by key, so that the TTL never
+		// depends on the order of the map
+		if len(suffix) > matchedLen || (len(suffix) == matchedLen && zone < matched) {
+			matched, matchedLen, ttl = zone, len(suffix), zoneTTL
+		}
 	}
 
-	return client.Do(req)
+	switch {
+	case ttl == 0:
+		return minTTL
+	case ttl < minTTL:
+		klog.Warningf("TTL %d of the TXT records in zone %q is lower than the minimum accepted by GoDaddy, using %d", ttl, domainZone, minTTL)
+		return minTTL
+	}
+	return ttl
 }
 
+// withTestOnlyKey returns a copy of ch holding TestOnlyKey instead of its key
+// if set, which validate only allows outside of production.
+func withTestOnlyKey(cfg godaddyDNSProviderConfig, ch *v1alpha1.ChallengeRequest) *v1alpha1.ChallengeRequest {
+	if cfg.TestOnlyKey == "" || cfg.Production {
+		return ch
+	}
+	klog.Warningf("Presenting the test only key instead of the challenge key of %q", ch.ResolvedFQDN)
+	overridden := *ch
+	overridden.Key = cfg.TestOnlyKey
+	return &overridden
+}
+
+// validateValue checks that the TXT record value does not exceed the
+// configured maximum length.
+func validateValue(cfg godaddyDNSProviderConfig, value string) error {
+	maxLength := cfg.MaxValueLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxValueLength
+	}
+	if len(value) > maxLength {
+		return fmt.Errorf("TXT record value is %d characters long, exceeding the maximum of %d", len(value), maxLength)
+	}
+	return nil
+}
+
+// validateFQDN checks that fqdn is a syntactically valid domain name, so that
+// malformed challenges are rejected before any zone detection or API call.
+func validateFQDN(fqdn string) error {
+	name := util.UnFqdn(fqdn)
+	if name == "" {
+		return errors.New("invalid FQDN: empty name")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("invalid FQDN %q: longer than 253 characters", fqdn)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return fmt.Errorf("invalid FQDN %q: empty label", fqdn)
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("invalid FQDN %q: label %q longer than 63 characters", fqdn, label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid FQDN %q: label %q starts or ends with a hyphen", fqdn, label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return fmt.Errorf("invalid FQDN %q: label %q contains invalid character %q", fqdn, label, r)
+			}
+		}
+	}
+	return nil
+}
+
+// challengeRecordName returns the name of the TXT record fqdn within zone,
+// without its _acme-challenge prefix if StripChallengePrefix is set.
+func (c *godaddyDNSSolver) challengeRecordName(cfg godaddyDNSProviderConfig, fqdn string, zone string) string {
+	name := c.extractRecordName(fqdn, zone)
+	if !cfg.StripChallengePrefix {
+		return name
+	}
+	if name == challengePrefix {
+		return "@"
+	}
+	return strings.TrimPrefix(name, challengePrefix+".")
+}
+
+// checkRecordName returns name lowercased if ValidateRecordName is set, or an
+// error if its labels or its full name within zone exceed the DNS limits.
+func checkRecordName(cfg godaddyDNSProviderConfig, name, zone string) (string, error) {
+	if !cfg.ValidateRecordName {
+		return name, nil
+	}
+	name = strings.ToLower(strings.Trim(name, "."))
+	fqdn := zone
+	if name != "@" {
+		fqdn = name + "." + zone
+	}
+	if err := validateFQDN(fqdn); err != nil {
+		return "", fmt.Errorf("invalid record name %q in zone %s: %v", name, zone, err)
+	}
+	return name, nil
+}
+
+// extractRecordName returns the name of fqdn relative to domain, "@" for the
+// apex of domain as GoDaddy names it. The names are compared regardless of
+// their case and trailing dot.
 func (c *godaddyDNSSolver) extractRecordName(fqdn, domain string) string {
-	if idx := strings.Index(fqdn, "."+domain); idx != -1 {
-		return fqdn[:idx]
+	name, domain := util.UnFqdn(fqdn), util.UnFqdn(domain)
+	if name == "" || strings.EqualFold(name, domain) {
+		return "@"
+	}
+	if suffix := "." + domain; len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
 	}
-	return util.UnFqdn(fqdn)
+	return name
 }
 
-func (c *godaddyDNSSolver) extractDomainName(zone string) string {
-	authZone, err := util.FindZoneByFqdn(zone, util.RecursiveNameservers)
-	if err != nil {
-		return zone
+// nameservers returns the resolvers queried to find the zones, Nameservers if
+// set or the public resolvers of cert-manager otherwise.
+func nameservers(cfg godaddyDNSProviderConfig) []string {
+	if len(cfg.Nameservers) > 0 {
+		return cfg.Nameservers
 	}
-	return util.UnFqdn(authZone)
+	return util.RecursiveNameservers
 }
 
-func (c *godaddyDNSSolver) getZone(fqdn string) (string, error) {
-	authZone, err := util.FindZoneByFqdn(fqdn, util.RecursiveNameservers)
+// getZone returns the zone of fqdn. The zones found through DNS are cached
+// for ZoneCacheTTL seconds, and the last one found is used if the lookup fails
+// and UseLastKnownZoneOnError is set.
+func (c *godaddyDNSSolver) getZone(cfg godaddyDNSProviderConfig, fqdn string) (string, error) {
+	find := findZoneByFqdn
+	switch cfg.ZoneDetection {
+	case "", zoneDetectionNS:
+	case zoneDetectionSOA:
+		find = findZoneBySOA
+	case zoneDetectionPublicSuffix:
+	default:
+		return "", fmt.Errorf("invalid zoneDetection %q, must be %s, %s or %s",
+			cfg.ZoneDetection, zoneDetectionNS, zoneDetectionSOA, zoneDetectionPublicSuffix)
+	}
+
+	if zone, ok := zoneByKnownSuffix(fqdn, cfg.KnownZoneSuffixes); ok {
+		return util.UnFqdn(zone), nil
+	}
+	if cfg.ZoneDetection == zoneDetectionPublicSuffix {
+		zone, err := findZoneByPublicSuffix(fqdn)
+		return util.UnFqdn(zone), err
+	}
+
+	name := strings.ToLower(util.ToFqdn(fqdn))
+	servers := nameservers(cfg)
+	// A zone found without cross-check must not be served to the issuers
+	// requiring one
+	cacheKey := strings.Join([]string{cfg.ZoneDetection, strconv.Itoa(cfg.ZoneCrossCheck), strings.Join(servers, ","), name}, "\x00")
+	if zone, ok := c.zoneCache.get(cacheKey); ok {
+		return zone, nil
+	}
+
+	release := c.zoneLookups.acquire(cfg.MaxConcurrentZoneLookups)
+	done := tracePhase(cfg, "zone lookup")
+	authZone, err := find(fqdn, servers)
+	if err == nil {
+		err = crossCheckZone(fqdn, authZone, servers, cfg.ZoneCrossCheck)
+	}
+	done()
+	release()
 	if err != nil {
+		if zone, ok := c.lastZones.get(cacheKey); ok && cfg.UseLastKnownZoneOnError {
+			klog.Warningf("Could not find the zone of %s, using the last zone found %s: %v", fqdn, zone, err)
+			return zone, nil
+		}
 		return "", err
 	}
 
-	return util.UnFqdn(authZone), nil
+	zone := util.UnFqdn(authZone)
+	c.lastZones.put(cacheKey, zone)
+	if ttl := zoneCacheTTL(cfg); ttl > 0 {
+		c.zoneCache.put(cacheKey, zone, ttl)
+	}
+	return zone, nil
 }
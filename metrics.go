@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// metricsNamespace prefixes the names of the metrics of the webhook.
+const metricsNamespace = "godaddy_webhook"
+
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_requests_total",
+		Help:      "Number of requests sent to the GoDaddy API, by method and status code.",
+	}, []string{"method", "code"})
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of the requests sent to the GoDaddy API, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "operations_total",
+		Help:      "Number of presents and cleanups, by outcome.",
+	}, []string{"operation", "outcome"})
+)
+
+// The metrics are served on the /metrics endpoint of the webhook server,
+// along with those of the apiserver library.
+func init() {
+	legacyregistry.RawMustRegister(apiRequests, apiRequestDuration, operations)
+}
+
+// observeRequest starts measuring a request to the GoDaddy API. The returned
+// function records it given its status code, 0 if no response was received.
+func observeRequest(method string) func(statusCode int) {
+	start := now()
+	return func(statusCode int) {
+		code := "error"
+		if statusCode != 0 {
+			code = strconv.Itoa(statusCode)
+		}
+		apiRequests.WithLabelValues(method, code).Inc()
+		apiRequestDuration.WithLabelValues(method).Observe(now().Sub(start).Seconds())
+	}
+}
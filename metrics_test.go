@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	fail := false
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[]`))
+		case fail:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer cleanup()
+
+	counters := map[string]func() float64{
+		"GET 200":         func() float64 { return testutil.ToFloat64(apiRequests.WithLabelValues("GET", "200")) },
+		"PUT 200":         func() float64 { return testutil.ToFloat64(apiRequests.WithLabelValues("PUT", "200")) },
+		"PUT 400":         func() float64 { return testutil.ToFloat64(apiRequests.WithLabelValues("PUT", "400")) },
+		"present success": func() float64 { return testutil.ToFloat64(operations.WithLabelValues("present", "success")) },
+		"present error":   func() float64 { return testutil.ToFloat64(operations.WithLabelValues("present", "error")) },
+	}
+	before := map[string]float64{}
+	for name, value := range counters {
+		before[name] = value()
+	}
+
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	fail = true
+	if err := c.Present(ch); err == nil {
+		t.Fatal("expected Present to fail")
+	}
+
+	want := map[string]float64{"GET 200": 2, "PUT 200": 1, "PUT 400": 1, "present success": 1, "present error": 1}
+	for name, value := range counters {
+		if got := value() - before[name]; got != want[name] {
+			t.Errorf("expected %s to increase by %v, got %v", name, want[name], got)
+		}
+	}
+}
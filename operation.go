@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"k8s.io/klog"
+)
+
+// Outcomes of the operations in the structured logs.
+const (
+	outcomeSuccess = "success"
+	outcomeNoop    = "noop"
+	outcomeSkipped = "skipped"
+	outcomeError   = "error"
+)
+
+// operationLog is the structured log line of a Present or CleanUp. It must
+// never hold the challenge key nor the credentials.
+type operationLog struct {
+	Operation  string `json:"operation"`
+	Zone       string `json:"zone,omitempty"`
+	RecordName string `json:"recordName,omitempty"`
+	// Status is the status code of the last response of GoDaddy
+	Status     int    `json:"status,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Retries    int    `json:"retries"`
+	Outcome    string `json:"outcome"`
+	// Error is the message of the error of the operation, key redacted
+	Error string `json:"error,omitempty"`
+
+	start time.Time
+}
+
+// String formats op as key/value pairs, the strings quoted.
+func (op *operationLog) String() string {
+	line := fmt.Sprintf("operation=%q zone=%q recordName=%q status=%d durationMs=%d retries=%d outcome=%q",
+		op.Operation, op.Zone, op.RecordName, op.Status, op.DurationMs, op.Retries, op.Outcome)
+	if op.Error != "" {
+		line += fmt.Sprintf(" error=%q", op.Error)
+	}
+	return line
+}
+
+// maxLoggedBody is the length beyond which the bodies of the GoDaddy
+// responses are truncated in the logs.
+const maxLoggedBody = 512
+
+// startOperationLog starts recording the operation. The returned function logs
+// its end in a single line given the error the operation returned: as
+// key/value pairs, or as JSON if StructuredLogs is set. The line is logged at
+// error level with the error, key redacted, when the operation failed.
+func startOperationLog(cfg *godaddyDNSProviderConfig, operation string, key string) func(err error) {
+	op := &operationLog{Operation: operation, start: now()}
+	cfg.operation = op
+	return func(err error) {
+		op.DurationMs = int64(now().Sub(op.start) / time.Millisecond)
+		switch {
+		case err != nil:
+			op.Outcome = outcomeError
+			op.Error = errorForLog(*cfg, key, err)
+		case op.Outcome == "":
+			op.Outcome = outcomeSuccess
+		}
+		operations.WithLabelValues(operation, op.Outcome).Inc()
+
+		line := op.String()
+		if cfg.StructuredLogs {
+			encoded, jsonErr := json.Marshal(op)
+			if jsonErr != nil {
+				klog.Errorf("could not log the %s operation: %v", operation, jsonErr)
+				return
+			}
+			line = string(encoded)
+		}
+		if err != nil {
+			klog.Error(line)
+			return
+		}
+		klog.Info(line)
+	}
+}
+
+// errorForLog returns the message of err with the body of the GoDaddy
+// response, or the message it holds, truncated, and the challenge key
+// redacted.
+func errorForLog(cfg godaddyDNSProviderConfig, key string, err error) string {
+	msg := err.Error()
+	var apiErr *godaddy.APIError
+	if errors.As(err, &apiErr) {
+		for _, long := range []string{apiErr.Body, apiErr.Message} {
+			if len(long) > maxLoggedBody {
+				msg = strings.Replace(msg, long, long[:maxLoggedBody]+"... (truncated)", 1)
+			}
+		}
+	}
+	if key != "" {
+		msg = strings.Replace(msg, key, keyForLog(cfg, key), -1)
+	}
+	return msg
+}
+
+// recordOperation sets the zone and the record name of the operation of cfg,
+// if any.
+func recordOperation(cfg godaddyDNSProviderConfig, domainZone string, recordName string) {
+	if cfg.operation != nil {
+		cfg.operation.Zone = domainZone
+		cfg.operation.RecordName = recordName
+	}
+}
+
+// setOutcome overrides the successful outcome of the operation of cfg, if any.
+func setOutcome(cfg godaddyDNSProviderConfig, outcome string) {
+	if cfg.operation != nil {
+		cfg.operation.Outcome = outcome
+	}
+}
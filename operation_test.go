@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/klog"
+)
+
+func TestStructuredLogs(t *testing.T) {
+	var puts int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`[]`))
+		case http.MethodPut:
+			puts++
+			if puts == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		}
+	}))
+	defer cleanup()
+	ch = withConfig(t, ch, `{"structuredLogs": true, "retryBackoff": 1}`)
+
+	logs := captureLogs(func() {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+		if err := c.CleanUp(ch); err != nil {
+			t.Fatalf("CleanUp failed: %v", err)
+		}
+	})
+	if strings.Contains(logs, ch.Key) || strings.Contains(logs, "the-secret") {
+		t.Errorf("expected no secret in the logs, got:\n%s", logs)
+	}
+
+	var ops []map[string]interface{}
+	for _, line := range strings.Split(logs, "\n") {
+		if i := strings.Index(line, "{"); i != -1 {
+			var op map[string]interface{}
+			if err := json.Unmarshal([]byte(line[i:]), &op); err != nil {
+				t.Fatalf("invalid JSON log line %q: %v", line, err)
+			}
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) != 2 {
+		t.Fatalf("expected a JSON line per operation, got:\n%s", logs)
+	}
+
+	for _, field := range []string{"operation", "zone", "recordName", "status", "durationMs", "retries", "outcome"} {
+		if _, ok := ops[0][field]; !ok {
+			t.Errorf("expected field %q in %v", field, ops[0])
+		}
+	}
+	if ops[0]["operation"] != "present" || ops[0]["zone"] != "example.com" || ops[0]["recordName"] != "_acme-challenge" ||
+		ops[0]["status"] != float64(200) || ops[0]["retries"] != float64(1) || ops[0]["outcome"] != "success" {
+		t.Errorf("unexpected present log %v", ops[0])
+	}
+	if ops[1]["operation"] != "cleanup" || ops[1]["outcome"] != "noop" {
+		t.Errorf("unexpected cleanup log %v", ops[1])
+	}
+}
+
+func TestStructuredLogsFailure(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"INVALID_BODY","message":"invalid"}`))
+	}))
+	defer cleanup()
+
+	logs := captureLogs(func() {
+		if err := c.Present(withConfig(t, ch, `{"structuredLogs": true}`)); err == nil {
+			t.Fatal("expected Present to fail")
+		}
+	})
+	var lines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.Contains(line, `"operation"`) || strings.Contains(line, "operation=") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "E") {
+		t.Fatalf("expected a single JSON line at error level, got:\n%s", logs)
+	}
+	var op map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0][strings.Index(lines[0], "{"):]), &op); err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", lines[0], err)
+	}
+	if op["outcome"] != "error" || !strings.Contains(fmt.Sprint(op["error"]), "INVALID_BODY") {
+		t.Errorf("expected the error in the log line, got %v", op)
+	}
+}
+
+func TestOperationLogs(t *testing.T) {
+	longBody := `{"code":"INVALID_BODY","message":"` + strings.Repeat("x", 2*maxLoggedBody) + `"}`
+	fail := false
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[]`))
+		case fail:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(longBody))
+		}
+	}))
+	defer cleanup()
+
+	logs := captureLogs(func() {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if !strings.Contains(logs, `operation="present" zone="example.com" recordName="_acme-challenge" status=200 `) ||
+		!strings.Contains(logs, `outcome="success"`) {
+		t.Errorf("expected the end of the operation to be logged, got:\n%s", logs)
+	}
+
+	fail = true
+	logs = captureLogs(func() {
+		if err := c.Present(ch); err == nil {
+			t.Fatal("expected Present to fail")
+		}
+	})
+	var errorLines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.Contains(line, `operation="present"`) {
+			errorLines = append(errorLines, line)
+		}
+	}
+	if len(errorLines) != 1 || !strings.HasPrefix(errorLines[0], "E") || !strings.Contains(errorLines[0], "status=400 ") {
+		t.Fatalf("expected the failure to be logged once at error level, got:\n%s", logs)
+	}
+	errorLine := errorLines[0]
+	if !strings.Contains(errorLine, "(truncated)") || strings.Contains(errorLine, longBody) {
+		t.Errorf("expected the response body to be truncated, got %s", errorLine)
+	}
+	if strings.Contains(logs, ch.Key) || strings.Contains(logs, "the-secret") {
+		t.Errorf("expected no secret in the logs, got:\n%s", logs)
+	}
+}
+
+func TestVerboseDumps(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[{"data":"other","name":"_acme-challenge","ttl":600,"type":"TXT"}]`))
+		}
+	}))
+	defer cleanup()
+
+	for _, verbosity := range []string{"0", "4"} {
+		logs := captureLogs(func() {
+			fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+			klog.InitFlags(fs)
+			fs.Set("v", verbosity)
+			defer fs.Set("v", "0")
+
+			if err := c.Present(ch); err != nil {
+				t.Fatalf("Present failed: %v", err)
+			}
+		})
+
+		dumped := strings.Contains(logs, `GoDaddy response to GET /v1/domains/example.com/records/TXT/_acme-challenge: 200 [{"data":"<redacted>"`) &&
+			strings.Contains(logs, "GoDaddy request: PUT /v1/domains/example.com/records/TXT/_acme-challenge")
+		if dumped != (verbosity == "4") {
+			t.Errorf("at verbosity %s, expected dumps: %v, got:\n%s", verbosity, verbosity == "4", logs)
+		}
+		if strings.Contains(logs, "the-secret") || strings.Contains(logs, ch.Key) || strings.Contains(logs, `"other"`) {
+			t.Errorf("expected neither credentials nor values in the logs, got:\n%s", logs)
+		}
+	}
+
+	hashed := withConfig(t, ch, `{"logKeyHash": true}`)
+	cfg := godaddyDNSProviderConfig{LogKeyHash: true}
+	logs := captureLogs(func() {
+		fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+		klog.InitFlags(fs)
+		fs.Set("v", "4")
+		defer fs.Set("v", "0")
+
+		if err := c.Present(hashed); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+	})
+	if strings.Contains(logs, ch.Key) || !strings.Contains(logs, keyForLog(cfg, ch.Key)) || !strings.Contains(logs, keyForLog(cfg, "other")) {
+		t.Errorf("expected the values to be hashed in the dumps, got:\n%s", logs)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"k8s.io/klog"
+)
+
+const (
+	// defaultOrphanAge is how long after being presented a challenge that was
+	// never cleaned up is considered orphaned.
+	defaultOrphanAge = time.Hour
+	// maxPresentedValues bounds the number of values presentedValues
+	// remembers.
+	maxPresentedValues = 1024
+)
+
+// presentedValues remembers when the values of the challenges presented and
+// not cleaned up yet were presented. Only the latest maxPresentedValues values
+// are remembered, in memory. The zero value is ready to use.
+type presentedValues struct {
+	mu     sync.Mutex
+	values map[string]time.Time
+	order  []string
+}
+
+// add remembers that value was just presented, forgetting the oldest value
+// when full.
+func (p *presentedValues) add(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.values == nil {
+		p.values = map[string]time.Time{}
+	}
+	if _, ok := p.values[value]; !ok {
+		if len(p.order) >= maxPresentedValues {
+			delete(p.values, p.order[0])
+			p.order = p.order[1:]
+		}
+		p.order = append(p.order, value)
+	}
+	p.values[value] = now()
+}
+
+// remove forgets value, cleaned up.
+func (p *presentedValues) remove(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.values[value]; !ok {
+		return
+	}
+	delete(p.values, value)
+	for i, v := range p.order {
+		if v == value {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// presentedBefore returns the values presented before t.
+func (p *presentedValues) presentedBefore(t time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var values []string
+	for _, value := range p.order {
+		if p.values[value].Before(t) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// orphanedChallenges returns the values of records left over by the
+// challenges of this webhook: the values it cleaned up, and the values it
+// presented more than OrphanAge seconds ago without cleaning them up. The
+// values it does not know of, e.g. of the concurrent challenges of other
+// replicas or issuers, the values just presented again and keep are never
+// returned.
+func (c *godaddyDNSSolver) orphanedChallenges(cfg godaddyDNSProviderConfig, client *godaddy.Client, ch *v1alpha1.ChallengeRequest, records []godaddy.Record, keep []string) []string {
+	age := defaultOrphanAge
+	if cfg.OrphanAge > 0 {
+		age = time.Duration(cfg.OrphanAge) * time.Second
+	}
+
+	var orphans []string
+	for _, value := range append(c.stale.list(), c.live.presentedBefore(now().Add(-age))...) {
+		if containsString(keep, value) || containsString(orphans, value) || !client.HasData(records, value) {
+			continue
+		}
+		presented := *ch
+		presented.Key = value
+		if c.presented.has(idempotencyKey(&presented)) {
+			continue
+		}
+		orphans = append(orphans, value)
+	}
+	return orphans
+}
+
+// withOrphans returns values along with the orphaned challenges of recordName
+// if RemoveOrphans is set.
+func (c *godaddyDNSSolver) withOrphans(cfg godaddyDNSProviderConfig, client *godaddy.Client, ch *v1alpha1.ChallengeRequest, domainZone string, recordName string, values []string) ([]string, error) {
+	if !cfg.RemoveOrphans {
+		return values, nil
+	}
+	records, err := client.Records(domainZone, "TXT", recordName)
+	if err != nil {
+		return nil, err
+	}
+	orphans := c.orphanedChallenges(cfg, client, ch, records, values)
+	if len(orphans) == 0 {
+		return values, nil
+	}
+	klog.Infof("Removing %d orphaned challenges from TXT record %q in zone %q", len(orphans), recordName, domainZone)
+	return append(append([]string(nil), values...), orphans...), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
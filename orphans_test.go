@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestPresentedValues(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+
+	var p presentedValues
+	p.add("old")
+	*current = current.Add(time.Hour)
+	p.add("recent")
+	p.add("cleaned")
+	p.remove("cleaned")
+
+	if got := p.presentedBefore(current.Add(-time.Minute)); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("expected only the old value, got %v", got)
+	}
+
+	// Presenting a value again makes it recent
+	p.add("old")
+	if got := p.presentedBefore(current.Add(-time.Minute)); len(got) != 0 {
+		t.Errorf("expected no old value once presented again, got %v", got)
+	}
+
+	for i := 0; i < maxPresentedValues; i++ {
+		p.add(fmt.Sprintf("value-%d", i))
+	}
+	if _, ok := p.values["old"]; ok || len(p.order) != maxPresentedValues {
+		t.Errorf("expected the oldest values to be forgotten, got %d values", len(p.order))
+	}
+}
+
+func TestCleanUpRemoveOrphans(t *testing.T) {
+	for _, config := range []string{
+		`{"removeOrphans": true}`,
+		`{"removeOrphans": true, "coalesceCleanup": true}`,
+	} {
+		t.Run(config, func(t *testing.T) {
+			current, restore := fakeClock()
+			defer restore()
+
+			gd := newFakeGoDaddy(t)
+			c, ch, cleanup := newTestSolver(t, gd)
+			defer cleanup()
+			ch = withConfig(t, ch, config)
+
+			present := func(key string) {
+				keyCh := *ch
+				keyCh.Key = key
+				if err := c.Present(&keyCh); err != nil {
+					t.Fatalf("Present failed: %v", err)
+				}
+			}
+			present("old")
+			*current = current.Add(2 * time.Hour)
+			present("recent")
+			present(ch.Key)
+
+			path := "/v1/domains/example.com/records/TXT/_acme-challenge"
+			gd.records[path] = append(gd.records[path],
+				// Presented by another replica or issuer
+				godaddy.Record{Type: "TXT", Name: "_acme-challenge", Data: "foreign", TTL: 600},
+				// Cleaned up earlier, yet still there
+				godaddy.Record{Type: "TXT", Name: "_acme-challenge", Data: "stale", TTL: 600},
+			)
+			c.stale.add("stale")
+
+			if err := c.CleanUp(ch); err != nil {
+				t.Fatalf("CleanUp failed: %v", err)
+			}
+			values := gd.values("_acme-challenge")
+			sort.Strings(values)
+			if want := []string{"foreign", "recent"}; !reflect.DeepEqual(values, want) {
+				t.Errorf("expected %v to remain, got %v", want, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"k8s.io/klog"
+)
+
+const (
+	// defaultPollingInterval is the time between DNS propagation checks.
+	defaultPollingInterval = 2 * time.Second
+	// defaultPropagationTimeout bounds the propagation wait enabled by
+	// WaitForPropagation without PropagationTimeout.
+	defaultPropagationTimeout = 2 * time.Minute
+)
+
+var (
+	// preCheckDNS is replaced in tests to avoid DNS lookups.
+	preCheckDNS = util.PreCheckDNS
+	// sleep is replaced in tests to avoid waiting between checks.
+	sleep = godaddy.Sleep
+)
+
+// waitForPropagation waits until the TXT record fqdn holds value, checking
+// every PollingInterval seconds for at most PropagationTimeout seconds. Nothing
+// is checked unless WaitForPropagation is set. The
+// recursive nameservers, or Nameservers if set, are queried unless
+// PropagationResolver is set. The names ending with one of
+// SkipPropagationSuffixes are not checked either.
+// The poll phase has its own deadline: it is not bound by HttpTimeout, which
+// only applies to each request to the GoDaddy API.
+func waitForPropagation(cfg godaddyDNSProviderConfig, fqdn string, value string) error {
+	timeout := propagationTimeout(cfg)
+	if timeout <= 0 {
+		return nil
+	}
+	if hasSuffix(fqdn, cfg.SkipPropagationSuffixes) {
+		klog.Infof("Skipping the propagation check of TXT record %q", fqdn)
+		return nil
+	}
+
+	interval := pollingInterval(cfg)
+
+	// The configured resolver is queried directly, instead of the
+	// authoritative nameservers found through the recursive ones
+	servers, useAuthoritative := nameservers(cfg), true
+	if cfg.PropagationResolver != "" {
+		servers, useAuthoritative = []string{resolverAddress(cfg.PropagationResolver)}, false
+	}
+
+	deadline := now().Add(timeout)
+	for {
+		ok, err := preCheckDNS(fqdn, value, servers, useAuthoritative)
+		if err == nil && ok {
+			return nil
+		}
+		if !now().Before(deadline) {
+			if err != nil {
+				return fmt.Errorf("TXT record %q not propagated after %v: %v", fqdn, timeout, err)
+			}
+			return fmt.Errorf("TXT record %q not propagated after %v", fqdn, timeout)
+		}
+		if err := sleep(cfg.context(), interval); err != nil {
+			return err
+		}
+	}
+}
+
+// propagationTimeout returns how long the propagation is waited for, 0 if it
+// is not.
+func propagationTimeout(cfg godaddyDNSProviderConfig) time.Duration {
+	switch {
+	case !cfg.WaitForPropagation:
+		return 0
+	case cfg.PropagationTimeout > 0:
+		return time.Duration(cfg.PropagationTimeout) * time.Second
+	}
+	return defaultPropagationTimeout
+}
+
+// pollingInterval returns the time between two checks of the records.
+func pollingInterval(cfg godaddyDNSProviderConfig) time.Duration {
+	if cfg.PollingInterval > 0 {
+		return time.Duration(cfg.PollingInterval) * time.Second
+	}
+	return defaultPollingInterval
+}
+
+// hasSuffix reports whether fqdn is one of suffixes or a subdomain of one.
+func hasSuffix(fqdn string, suffixes []string) bool {
+	name := strings.ToLower(util.UnFqdn(fqdn))
+	for _, suffix := range suffixes {
+		suffix = strings.ToLower(util.UnFqdn(strings.TrimPrefix(suffix, ".")))
+		if suffix != "" && (name == suffix || strings.HasSuffix(name, "."+suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolverAddress returns the address of resolver, on port 53 unless it
+// specifies one.
+func resolverAddress(resolver string) string {
+	if _, _, err := net.SplitHostPort(resolver); err == nil {
+		return resolver
+	}
+	return net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+var origPreCheckDNS = preCheckDNS
+
+// fakeClock makes now and sleep follow a simulated clock.
+func fakeClock() (*time.Time, func()) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	sleep = func(ctx context.Context, d time.Duration) error {
+		current = current.Add(d)
+		return ctx.Err()
+	}
+	return &current, func() {
+		now = time.Now
+		sleep = godaddy.Sleep
+	}
+}
+
+func TestPresentWaitsForPropagationLongerThanHttpTimeout(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+	start := *current
+
+	var checks int
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		checks++
+		// The record shows up after a minute, far beyond the API timeout
+		return current.Sub(start) >= time.Minute, nil
+	}
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+
+	ch = withConfig(t, ch, `{"timeout": 1, "waitForPropagation": true, "propagationTimeout": 120, "pollingInterval": 10}`)
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if elapsed := current.Sub(start); elapsed < time.Minute || elapsed > 2*time.Minute {
+		t.Errorf("expected the poll phase to last about a minute, got %v", elapsed)
+	}
+	if checks != 7 {
+		t.Errorf("expected 7 checks, got %d", checks)
+	}
+}
+
+func TestWaitForPropagationTimeout(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+	start := *current
+
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		return false, nil
+	}
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	cfg := godaddyDNSProviderConfig{HttpTimeout: 1, WaitForPropagation: true, PropagationTimeout: 30, PollingInterval: 5}
+	if err := waitForPropagation(cfg, "_acme-challenge.example.com.", "key"); err == nil {
+		t.Fatal("expected the propagation check to time out")
+	}
+	if elapsed := current.Sub(start); elapsed != 30*time.Second {
+		t.Errorf("expected to give up after the propagation timeout, got %v", elapsed)
+	}
+}
+
+func TestPresentWaitForPropagation(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+	start := *current
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+
+	var checked []string
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		if !useAuthoritative {
+			t.Errorf("expected the authoritative nameservers to be checked")
+		}
+		checked = append(checked, value)
+		// The record shows up on the third check
+		return len(checked) == 3, nil
+	}
+	if err := c.Present(withConfig(t, ch, `{"waitForPropagation": true, "pollingInterval": 5}`)); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if len(checked) != 3 || checked[0] != ch.Key {
+		t.Errorf("expected the key to be checked 3 times, got %v", checked)
+	}
+	if elapsed := current.Sub(start); elapsed != 10*time.Second {
+		t.Errorf("expected to sleep the polling interval between the checks, got %v", elapsed)
+	}
+
+	// Without propagationTimeout, the wait is bounded by the default timeout
+	start = *current
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		return false, nil
+	}
+	if err := c.Present(withConfig(t, ch, `{"waitForPropagation": true}`)); err == nil {
+		t.Fatal("expected the propagation wait to time out")
+	}
+	if elapsed := current.Sub(start); elapsed != defaultPropagationTimeout {
+		t.Errorf("expected to give up after %v, got %v", defaultPropagationTimeout, elapsed)
+	}
+}
+
+func TestWaitForPropagationDisabled(t *testing.T) {
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		t.Error("unexpected propagation check")
+		return false, nil
+	}
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	// The timeout alone does not enable the check
+	for _, cfg := range []godaddyDNSProviderConfig{{}, {PropagationTimeout: 30}} {
+		if err := waitForPropagation(cfg, "_acme-challenge.example.com.", "key"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestWaitForPropagationCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		cancel()
+		return false, nil
+	}
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	// The polling interval is never waited for once the webhook stops
+	cfg := godaddyDNSProviderConfig{WaitForPropagation: true, PollingInterval: 3600, ctx: ctx}
+	if err := waitForPropagation(cfg, "_acme-challenge.example.com.", "key"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
+
+func TestWaitForPropagationResolver(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	tests := []struct {
+		resolver string
+		want     string
+	}{
+		{"ns1.example.com", "ns1.example.com:53"},
+		{"10.0.0.53:5353", "10.0.0.53:5353"},
+		{"2001:db8::53", "[2001:db8::53]:53"},
+		{"[2001:db8::53]", "[2001:db8::53]:53"},
+	}
+	for _, tt := range tests {
+		var queried []string
+		preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+			if useAuthoritative {
+				t.Errorf("expected the resolver to be queried directly")
+			}
+			queried = nameservers
+			return true, nil
+		}
+		cfg := godaddyDNSProviderConfig{WaitForPropagation: true, PropagationTimeout: 10, PropagationResolver: tt.resolver}
+		if err := waitForPropagation(cfg, "_acme-challenge.example.com.", "value"); err != nil {
+			t.Fatalf("waitForPropagation failed: %v", err)
+		}
+		if len(queried) != 1 || queried[0] != tt.want {
+			t.Errorf("expected resolver %q to be queried at %s, got %v", tt.resolver, tt.want, queried)
+		}
+	}
+}
+
+func TestWaitForPropagationNameservers(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	var queried []string
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		if !useAuthoritative {
+			t.Errorf("expected the authoritative nameservers to be checked")
+		}
+		queried = nameservers
+		return true, nil
+	}
+	cfg := godaddyDNSProviderConfig{WaitForPropagation: true, PropagationTimeout: 10, Nameservers: []string{"10.0.0.53:53"}}
+	if err := waitForPropagation(cfg, "_acme-challenge.example.com.", "value"); err != nil {
+		t.Fatalf("waitForPropagation failed: %v", err)
+	}
+	if len(queried) != 1 || queried[0] != "10.0.0.53:53" {
+		t.Errorf("expected the configured nameservers to be queried, got %v", queried)
+	}
+}
+
+func TestWaitForPropagationSkipSuffixes(t *testing.T) {
+	_, restore := fakeClock()
+	defer restore()
+	defer func() { preCheckDNS = origPreCheckDNS }()
+
+	var checked []string
+	preCheckDNS = func(fqdn, value string, nameservers []string, useAuthoritative bool) (bool, error) {
+		checked = append(checked, fqdn)
+		return true, nil
+	}
+
+	cfg := godaddyDNSProviderConfig{
+		WaitForPropagation:      true,
+		PropagationTimeout:      10,
+		SkipPropagationSuffixes: []string{".ote.example.com", "internal."},
+	}
+	tests := []struct {
+		fqdn  string
+		check bool
+	}{
+		{"_acme-challenge.www.ote.example.com.", false},
+		{"_acme-challenge.OTE.example.com.", false},
+		{"ote.example.com.", false},
+		{"_acme-challenge.example.internal.", false},
+		{"_acme-challenge.example.com.", true},
+		{"_acme-challenge.remote.example.com.", true},
+		{"_acme-challenge.internal.example.com.", true},
+	}
+	for _, tt := range tests {
+		checked = nil
+		if err := waitForPropagation(cfg, tt.fqdn, "value"); err != nil {
+			t.Fatalf("waitForPropagation(%q) failed: %v", tt.fqdn, err)
+		}
+		if got := len(checked) > 0; got != tt.check {
+			t.Errorf("waitForPropagation(%q) checked propagation: %v, expected %v", tt.fqdn, got, tt.check)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jetstack/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+	"golang.org/x/net/publicsuffix"
+)
+
+// findZoneByPublicSuffix returns the registrable domain of fqdn, i.e. its
+// public suffix along with the label preceding it, without any DNS lookup.
+func findZoneByPublicSuffix(fqdn string) (string, error) {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(util.UnFqdn(fqdn)))
+	if err != nil {
+		return "", fmt.Errorf("could not find the zone of %s: %v", fqdn, err)
+	}
+	return util.ToFqdn(domain), nil
+}
+
+// withRegistrableZone returns ch with its zone set to the registrable domain
+// of its FQDN if ZoneDetection is publicSuffix, so that the record name is
+// relative to that zone. ch itself is left untouched.
+func withRegistrableZone(cfg godaddyDNSProviderConfig, ch *v1alpha1.ChallengeRequest) (*v1alpha1.ChallengeRequest, error) {
+	if cfg.ZoneDetection != zoneDetectionPublicSuffix {
+		return ch, nil
+	}
+	zone, err := findZoneByPublicSuffix(ch.ResolvedFQDN)
+	if err != nil {
+		return nil, err
+	}
+	derived := *ch
+	derived.ResolvedZone = zone
+	return &derived, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+func TestFindZoneByPublicSuffix(t *testing.T) {
+	tests := []struct {
+		fqdn string
+		want string
+	}{
+		{"_acme-challenge.example.com.", "example.com."},
+		{"_acme-challenge.www.Example.COM", "example.com."},
+		{"_acme-challenge.example.dev.", "example.dev."},
+		{"_acme-challenge.example.co.uk.", "example.co.uk."},
+		{"_acme-challenge.www.example.co.uk.", "example.co.uk."},
+		{"_acme-challenge.shop.example.com.au.", "example.com.au."},
+		{"_acme-challenge.example.co.jp.", "example.co.jp."},
+		{"_acme-challenge.a.b.example.co.ke.", "example.co.ke."},
+		{"_acme-challenge.example.uk.", "example.uk."},
+		{"co.uk.", ""},
+		{"com.", ""},
+	}
+	for _, tt := range tests {
+		zone, err := findZoneByPublicSuffix(tt.fqdn)
+		if tt.want == "" && err == nil {
+			t.Errorf("findZoneByPublicSuffix(%q) expected an error, got %q", tt.fqdn, zone)
+		}
+		if tt.want != "" && (err != nil || zone != tt.want) {
+			t.Errorf("findZoneByPublicSuffix(%q) = %q, %v, want %q", tt.fqdn, zone, err, tt.want)
+		}
+	}
+}
+
+func TestPresentPublicSuffix(t *testing.T) {
+	var paths []string
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("[]"))
+	}))
+	defer cleanup()
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		t.Errorf("unexpected DNS lookup of %s", fqdn)
+		return "", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	// cert-manager resolved a delegated subzone, the record still goes to
+	// the registrable domain
+	ch.ResolvedFQDN, ch.ResolvedZone = "_acme-challenge.shop.example.co.uk.", "shop.example.co.uk."
+	if err := c.Present(withConfig(t, ch, `{"zoneDetection": "publicSuffix"}`)); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("expected the record to be written")
+	}
+	for _, path := range paths {
+		if !strings.HasSuffix(path, "/v1/domains/example.co.uk/records/TXT/_acme-challenge.shop") {
+			t.Errorf("expected the record _acme-challenge.shop of example.co.uk, got %s", path)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// recordQuota tracks the challenge records presented and not cleaned up yet,
+// in memory only: the count starts over when the webhook restarts. The zero
+// value is ready to use.
+type recordQuota struct {
+	mu      sync.Mutex
+	records map[string]struct{}
+}
+
+// reserve counts the record identified by key against limit. It returns
+// whether the record was not counted yet, and an error if presenting it would
+// exceed limit.
+func (q *recordQuota) reserve(key string, limit int) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.records[key]; ok {
+		return false, nil
+	}
+	if len(q.records) >= limit {
+		return false, fmt.Errorf("record quota exceeded: %d challenge records are presented and not cleaned up yet, "+
+			"investigate why so many certificates are being issued before raising recordQuota", len(q.records))
+	}
+	if q.records == nil {
+		q.records = map[string]struct{}{}
+	}
+	q.records[key] = struct{}{}
+	return true, nil
+}
+
+// release stops counting the record identified by key.
+func (q *recordQuota) release(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.records, key)
+}
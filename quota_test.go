@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPresentRecordQuota(t *testing.T) {
+	gd := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, gd)
+	defer cleanup()
+	ch = withConfig(t, ch, `{"recordQuota": 2}`)
+
+	challenges := make([]string, 3)
+	for i, name := range []string{"a", "b", "c"} {
+		challenges[i] = "_acme-challenge." + name + ".example.com."
+	}
+	present := func(fqdn string) error {
+		challenge := *ch
+		challenge.ResolvedFQDN = fqdn
+		return c.Present(&challenge)
+	}
+
+	for _, fqdn := range challenges[:2] {
+		if err := present(fqdn); err != nil {
+			t.Fatalf("Present(%q) failed: %v", fqdn, err)
+		}
+	}
+	// Presenting a counted record again does not count twice
+	if err := present(challenges[0]); err != nil {
+		t.Fatalf("presenting %q again failed: %v", challenges[0], err)
+	}
+
+	err := present(challenges[2])
+	if err == nil || !strings.Contains(err.Error(), "record quota exceeded") {
+		t.Fatalf("expected the quota to be exceeded, got %v", err)
+	}
+	if values := gd.values("_acme-challenge.c"); len(values) != 0 {
+		t.Errorf("expected no record to be created beyond the quota, got %v", values)
+	}
+
+	cleaned := *ch
+	cleaned.ResolvedFQDN = challenges[0]
+	if err := c.CleanUp(&cleaned); err != nil {
+		t.Fatalf("CleanUp failed: %v", err)
+	}
+	if err := present(challenges[2]); err != nil {
+		t.Errorf("expected a cleaned up record to free the quota, got %v", err)
+	}
+}
+
+func TestPresentRecordQuotaFailedWrite(t *testing.T) {
+	fail := true
+	gd := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail && r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gd.ServeHTTP(w, r)
+	}))
+	defer cleanup()
+	ch = withConfig(t, ch, `{"recordQuota": 1}`)
+
+	if err := c.Present(ch); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	fail = false
+	other := *ch
+	other.ResolvedFQDN = "_acme-challenge.www.example.com."
+	if err := c.Present(&other); err != nil {
+		t.Errorf("expected a failed write not to count against the quota, got %v", err)
+	}
+}
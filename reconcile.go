@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"k8s.io/klog"
+)
+
+// reconcileTXT makes the TXT records of recordName hold the values of add and
+// none of the values of remove, with a single update made only when something
+// changes. Present and CleanUp both go through it, so that the records of
+// other challenges are always preserved the same way. It reports whether the
+// records were updated.
+func reconcileTXT(cfg godaddyDNSProviderConfig, client *godaddy.Client, domainZone string, recordName string, add []string, remove []string) (bool, error) {
+	if cfg.SnapshotBeforeWrite {
+		records, err := client.Records(domainZone, "TXT", recordName)
+		if err != nil {
+			return false, zoneAccessError(domainZone, err)
+		}
+		if err := snapshotRecords(cfg, domainZone, recordName, records); err != nil {
+			return false, err
+		}
+	}
+
+	if cfg.CheckCNAMEBeforeWrite {
+		conflict, err := findCNAME(client, domainZone, recordName)
+		if err != nil {
+			return false, zoneAccessError(domainZone, err)
+		}
+		if conflict != nil {
+			return false, conflict
+		}
+	}
+
+	changed, err := client.ReconcileTXT(domainZone, recordName, add, remove, recordTTL(cfg, domainZone))
+	// GoDaddy refuses the TXT records along a CNAME as conflicting or
+	// unprocessable with a generic message, the CNAME is only looked for then
+	if isRecordConflict(err) {
+		if conflict, _ := findCNAME(client, domainZone, recordName); conflict != nil {
+			conflict.err = err
+			return false, conflict
+		}
+	}
+	return changed, zoneAccessError(domainZone, err)
+}
+
+// cnameConflictError is returned when a CNAME record exists at the name of the
+// challenge, where no other record can be created.
+type cnameConflictError struct {
+	zone   string
+	name   string
+	target string
+	err    error
+}
+
+func (e *cnameConflictError) Error() string {
+	return fmt.Sprintf("a CNAME record to %s exists at %s in domain %s, so GoDaddy does not allow the TXT record of the challenge: "+
+		"remove the CNAME, or set cnameStrategy: Follow in the DNS01 solver to present the challenge at its target",
+		e.target, e.name, e.zone)
+}
+
+func (e *cnameConflictError) Unwrap() error {
+	return e.err
+}
+
+// isRecordConflict reports whether GoDaddy refused a write as conflicting with
+// the records of the zone, i.e. answered 409 or 422.
+func isRecordConflict(err error) bool {
+	var apiErr *godaddy.APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// findCNAME returns a cnameConflictError if a CNAME record exists at
+// recordName in domainZone.
+func findCNAME(client *godaddy.Client, domainZone string, recordName string) (*cnameConflictError, error) {
+	// The apex of a zone cannot hold a CNAME
+	if recordName == "@" {
+		return nil, nil
+	}
+	records, err := client.Records(domainZone, "CNAME", recordName)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &cnameConflictError{zone: domainZone, name: recordName, target: records[0].Data}, nil
+}
+
+// unmanagedZoneError is returned when GoDaddy does not let the account manage
+// the zone, usually because the credentials belong to another account.
+type unmanagedZoneError struct {
+	zone       string
+	statusCode int
+	err        error
+}
+
+func (e *unmanagedZoneError) Error() string {
+	return fmt.Sprintf("domain %s is not managed by this GoDaddy account or the API key lacks access (status: %d): %v", e.zone, e.statusCode, e.err)
+}
+
+func (e *unmanagedZoneError) Unwrap() error {
+	return e.err
+}
+
+// zoneAccessError returns err with an actionable message when GoDaddy answered
+// 403 or 404 for domainZone. The whole body of the response is only logged at
+// verbosity 4.
+func zoneAccessError(domainZone string, err error) error {
+	var apiErr *godaddy.APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusForbidden && apiErr.StatusCode != http.StatusNotFound) {
+		return err
+	}
+	klog.V(4).Infof("GoDaddy answered %d for zone %q: %s", apiErr.StatusCode, domainZone, apiErr.Body)
+	return &unmanagedZoneError{zone: domainZone, statusCode: apiErr.StatusCode, err: err}
+}
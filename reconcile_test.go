@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestPresentUnmanagedZone(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":"UNKNOWN_DOMAIN","message":"raw body"}`))
+		}))
+
+		err := c.Present(ch)
+		cleanup()
+
+		want := "domain example.com is not managed by this GoDaddy account or the API key lacks access"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%d: expected %q, got %v", status, want, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "Code: UNKNOWN_DOMAIN") {
+			t.Errorf("%d: expected the API error in the message, got %v", status, err)
+		}
+		if forbidden := strings.Contains(err.Error(), "not allowed to manage the domain"); forbidden != (status == http.StatusForbidden) {
+			t.Errorf("%d: expected the explanation of the forbidden error: %v, got %v", status, status == http.StatusForbidden, err)
+		}
+		var apiErr *godaddy.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != status || !strings.Contains(apiErr.Body, "raw body") {
+			t.Errorf("%d: expected the API error to stay available, got %v", status, err)
+		}
+		if godaddy.IsForbidden(err) != (status == http.StatusForbidden) {
+			t.Errorf("%d: expected the error to keep its type", status)
+		}
+	}
+}
+
+func TestPresentOtherErrorsUnchanged(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer cleanup()
+
+	if err := c.Present(ch); err == nil || strings.Contains(err.Error(), "not managed") {
+		t.Errorf("expected the API error as is, got %v", err)
+	}
+}
+
+// cnameConflictHandler serves a CNAME record at _acme-challenge and refuses
+// the writes of TXT records there, counting them in puts.
+func cnameConflictHandler(puts *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/records/CNAME/_acme-challenge"):
+			w.Write([]byte(`[{"type":"CNAME","name":"_acme-challenge","data":"acme.example.net","ttl":600}]`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[]`))
+		default:
+			*puts++
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"code":"INVALID_BODY","message":"Request body doesn't fulfill schema"}`))
+		}
+	})
+}
+
+func TestPresentCNAMEConflict(t *testing.T) {
+	var puts int
+	c, ch, cleanup := newTestSolver(t, cnameConflictHandler(&puts))
+	defer cleanup()
+
+	err := c.Present(ch)
+	var conflict *cnameConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a CNAME conflict, got %v", err)
+	}
+	for _, want := range []string{"acme.example.net", "_acme-challenge", "cnameStrategy: Follow"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to mention %q, got %v", want, err)
+		}
+	}
+	var apiErr *godaddy.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected the refused write to stay available, got %v", err)
+	}
+	if puts != 1 {
+		t.Errorf("expected the TXT record to be written once, got %d writes", puts)
+	}
+}
+
+func TestPresentCheckCNAMEBeforeWrite(t *testing.T) {
+	var puts int
+	c, ch, cleanup := newTestSolver(t, cnameConflictHandler(&puts))
+	defer cleanup()
+
+	err := c.Present(withConfig(t, ch, `{"checkCNAMEBeforeWrite": true}`))
+	var conflict *cnameConflictError
+	if !errors.As(err, &conflict) || conflict.target != "acme.example.net" {
+		t.Fatalf("expected a CNAME conflict, got %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("expected no write with a CNAME at the name, got %d writes", puts)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sequencer spaces out the writes to GoDaddy, so that the challenges
+// presented at once do not hit its rate limits, like the sequenced providers
+// of lego. The zero value is ready to use.
+type sequencer struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// wait blocks until interval has elapsed since the previous write started, or
+// until ctx is done. Writes are not spaced out if interval is 0.
+func (s *sequencer) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The writes queued behind a cancelled one give up in turn
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d := s.last.Add(interval).Sub(now()); d > 0 {
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+	s.last = now()
+	return nil
+}
+
+// sequence waits for the turn of the write of the operation of cfg, at least
+// SequenceInterval seconds after the previous write.
+func (c *godaddyDNSSolver) sequence(cfg godaddyDNSProviderConfig) error {
+	return c.writes.wait(cfg.context(), time.Duration(cfg.SequenceInterval)*time.Second)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequencerSpacesCalls(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	current, restore := fakeClock()
+	defer restore()
+	start := *current
+
+	// The delays are recorded while the sequencer is held, one call at a time
+	advance := sleep
+	var delays []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return advance(ctx, d)
+	}
+
+	var s sequencer
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.wait(context.Background(), interval); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first call goes right away, each of the others a full interval
+	// after the previous one
+	want := []time.Duration{interval, interval, interval}
+	if !reflect.DeepEqual(delays, want) {
+		t.Errorf("expected the delays %v, got %v", want, delays)
+	}
+	if elapsed := current.Sub(start); elapsed != 3*interval {
+		t.Errorf("expected the calls to span %v, got %v", 3*interval, elapsed)
+	}
+}
+
+func TestSequencerDisabled(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+	start := *current
+
+	var s sequencer
+	for i := 0; i < 3; i++ {
+		if err := s.wait(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := current.Sub(start); elapsed != 0 {
+		t.Errorf("calls waited %v without an interval", elapsed)
+	}
+}
+
+func TestSequencerCancelled(t *testing.T) {
+	var s sequencer
+	if err := s.wait(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- s.wait(ctx, time.Hour) }()
+	}
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("wait returned %v, want %v", err, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait blocked after the context was cancelled")
+		}
+	}
+}
+
+func TestPresentSequenceCancelledOnStop(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, nil)
+	defer cleanup()
+	ch = withConfig(t, ch, `{"sequenceInterval": 3600}`)
+
+	stopCh := make(chan struct{})
+	c.ctx = stopContext(stopCh)
+	c.writes.last = time.Now()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Present(ch) }()
+	close(stopCh)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Present returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Present blocked after the solver was stopped")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "sync"
+
+// maxStaleValues bounds the number of values staleValues remembers.
+const maxStaleValues = 1024
+
+// staleValues remembers the values of the challenges cleaned up, which are
+// left over if they are still found in a record afterwards. Only the latest
+// maxStaleValues values are remembered, in memory. The zero value is ready to
+// use.
+type staleValues struct {
+	mu     sync.Mutex
+	values map[string]struct{}
+	order  []string
+}
+
+// add remembers value, forgetting the oldest one when full.
+func (s *staleValues) add(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[value]; ok {
+		return
+	}
+	if s.values == nil {
+		s.values = map[string]struct{}{}
+	}
+	if len(s.order) >= maxStaleValues {
+		delete(s.values, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.values[value] = struct{}{}
+	s.order = append(s.order, value)
+}
+
+// remove forgets value, presented again.
+func (s *staleValues) remove(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[value]; !ok {
+		return
+	}
+	delete(s.values, value)
+	for i, v := range s.order {
+		if v == value {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// list returns the values remembered, oldest first.
+func (s *staleValues) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.order...)
+}
+
+// has reports whether value is left over by a challenge cleaned up.
+func (s *staleValues) has(value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.values[value]
+	return ok
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestStaleValues(t *testing.T) {
+	var s staleValues
+	if s.has("a") {
+		t.Errorf("expected no stale value at first")
+	}
+
+	s.add("a")
+	s.add("a")
+	if !s.has("a") || len(s.order) != 1 {
+		t.Errorf("expected a to be remembered once, got %v", s.order)
+	}
+	s.remove("a")
+	if s.has("a") || len(s.order) != 0 {
+		t.Errorf("expected a to be forgotten, got %v", s.order)
+	}
+
+	for i := 0; i <= maxStaleValues; i++ {
+		s.add(strconv.Itoa(i))
+	}
+	if s.has("0") || !s.has("1") || !s.has(strconv.Itoa(maxStaleValues)) {
+		t.Errorf("expected only the oldest value to be forgotten")
+	}
+	if len(s.values) != maxStaleValues || len(s.order) != maxStaleValues {
+		t.Errorf("expected %d values, got %d and %d", maxStaleValues, len(s.values), len(s.order))
+	}
+}
+
+func TestPresentPrunesStaleValues(t *testing.T) {
+	const leftover = "3x4mpl3L3ftOv3rK3y000000000000000000000000a"
+	var written []godaddy.Record
+	failWrites := true
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[{"type":"TXT","name":"_acme-challenge","data":"other","ttl":600},` +
+				`{"type":"TXT","name":"_acme-challenge","data":"` + leftover + `","ttl":600}]`))
+			return
+		}
+		if failWrites {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&written)
+	}))
+	defer cleanup()
+
+	// The cleanup of the leftover value fails, leaving it in the record
+	old := *ch
+	old.Key = leftover
+	if err := c.CleanUp(&old); err == nil {
+		t.Fatal("expected the cleanup to fail")
+	}
+
+	failWrites = false
+	ch = withConfig(t, ch, `{"recordLimit": 2, "onRecordLimitExceeded": "prune"}`)
+	if err := c.Present(ch); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	var values []string
+	for _, r := range written {
+		values = append(values, r.Data)
+	}
+	if strings.Join(values, ",") != "other,"+ch.Key {
+		t.Errorf("expected the leftover value to be pruned, got %v", values)
+	}
+}
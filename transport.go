@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// apiTransports holds the transports of the requests to GoDaddy, shared by
+// the operations so that they reuse the connections and TLS sessions instead
+// of opening new ones for each request. The zero value is ready to use.
+type apiTransports struct {
+	mu         sync.Mutex
+	transports map[transportKey]*http.Transport
+}
+
+// transportKey tells apart the transports configured differently.
+type transportKey struct {
+	disableHTTP2 bool
+	proxyURL     string
+}
+
+// get returns the transport of the requests of cfg, only speaking HTTP/1.1
+// if DisableHTTP2 is set and going through ProxyURL if set.
+func (a *apiTransports) get(cfg godaddyDNSProviderConfig) *http.Transport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := transportKey{disableHTTP2: cfg.DisableHTTP2, proxyURL: cfg.ProxyURL}
+	if t, ok := a.transports[key]; ok {
+		return t
+	}
+	if a.transports == nil {
+		a.transports = map[transportKey]*http.Transport{}
+	}
+	t := godaddy.NewTransport(cfg.DisableHTTP2)
+	if cfg.ProxyURL != "" {
+		// The hosts of NO_PROXY and localhost are still reached directly
+		proxy := (&httpproxy.Config{
+			HTTPProxy:  cfg.ProxyURL,
+			HTTPSProxy: cfg.ProxyURL,
+			NoProxy:    noProxyFromEnv(),
+		}).ProxyFunc()
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+	a.transports[key] = t
+	return t
+}
+
+// validateProxyURL checks that rawURL is the URL of an HTTP, HTTPS or SOCKS5
+// proxy.
+func validateProxyURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxyURL %q: %v", rawURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("invalid proxyURL %q: scheme must be http, https or socks5", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxyURL %q: missing host", rawURL)
+	}
+	return nil
+}
+
+// noProxyFromEnv returns the hosts that are not proxied, as set by NO_PROXY
+// or no_proxy.
+func noProxyFromEnv() string {
+	if noProxy := os.Getenv("NO_PROXY"); noProxy != "" {
+		return noProxy
+	}
+	return os.Getenv("no_proxy")
+}
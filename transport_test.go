@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestClientsShareTransport(t *testing.T) {
+	c := &godaddyDNSSolver{}
+	cfg := godaddyDNSProviderConfig{}
+	if c.newClient(cfg).Transport != c.newClient(cfg).Transport {
+		t.Errorf("expected the clients to share their transport")
+	}
+	http1 := godaddyDNSProviderConfig{DisableHTTP2: true}
+	if c.newClient(http1).Transport != c.newClient(http1).Transport {
+		t.Errorf("expected the HTTP/1.1 clients to share their transport")
+	}
+	if c.newClient(cfg).Transport == c.newClient(http1).Transport {
+		t.Errorf("expected HTTP/2 to be disabled on another transport")
+	}
+}
+
+func TestOperationsReuseConnections(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, http.NotFoundHandler())
+	defer cleanup()
+
+	var mu sync.Mutex
+	var conns int
+	srv := httptest.NewUnstartedServer(newFakeGoDaddy(t))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	// Disabling HTTP/2 used to create a transport per request
+	ch = withConfig(t, ch, `{"disableHTTP2": true, "apiBaseURL": "`+srv.URL+`"}`)
+	for i := 0; i < 3; i++ {
+		if err := c.Present(ch); err != nil {
+			t.Fatalf("Present failed: %v", err)
+		}
+		if err := c.CleanUp(ch); err != nil {
+			t.Fatalf("CleanUp failed: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("expected the operations to reuse a single connection, got %d", conns)
+	}
+}
+
+func TestPresentThroughProxy(t *testing.T) {
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected the request to go through the proxy, got %s %s", r.Method, r.URL)
+	}))
+	defer cleanup()
+
+	// The proxy only gets to see the tunnel to the API, and refuses it
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.Host)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+
+	err := c.Present(withConfig(t, ch, `{"apiBaseURL": "https://api.godaddy.test", "proxyURL": "`+proxy.URL+`"}`))
+	if err == nil {
+		t.Fatalf("expected the proxy to refuse the tunnel")
+	}
+	want := "CONNECT api.godaddy.test:443"
+	if len(proxied) == 0 || proxied[0] != want {
+		t.Errorf("expected %q to be proxied, got %v", want, proxied)
+	}
+}
+
+func TestTransportNoProxy(t *testing.T) {
+	for _, name := range []string{"NO_PROXY", "no_proxy"} {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+	os.Unsetenv("no_proxy")
+	os.Setenv("NO_PROXY", "internal.example, .corp, 10.0.0.0/8, api.ote-godaddy.com:443")
+
+	var a apiTransports
+	transport := a.get(godaddyDNSProviderConfig{ProxyURL: "http://proxy.internal:3128"})
+	tests := []struct {
+		url     string
+		proxied bool
+	}{
+		{"https://api.godaddy.com/v1/domains", true},
+		{"https://api.ote-godaddy.com/v1/domains", false},
+		{"https://internal.example/v1/domains", false},
+		{"https://api.internal.example/v1/domains", false},
+		{"https://notinternal.example/v1/domains", true},
+		{"https://api.corp/v1/domains", false},
+		{"http://10.1.2.3:8080/v1/domains", false},
+		{"http://192.168.1.1/v1/domains", true},
+		{"http://localhost:8080/v1/domains", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		got, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("Proxy failed: %v", err)
+		}
+		if (got != nil) != tt.proxied {
+			t.Errorf("%s: expected proxied %v, got proxy %v", tt.url, tt.proxied, got)
+		}
+	}
+}
+
+func TestValidateProxyURL(t *testing.T) {
+	for _, valid := range []string{"http://proxy:3128", "https://proxy.internal", "socks5://127.0.0.1:1080"} {
+		if err := validateProxyURL(valid); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"proxy:3128", "ftp://proxy", "http://", "://proxy"} {
+		if err := validateProxyURL(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+const (
+	defaultVerifyAttempts = 3
+	defaultVerifyInterval = time.Second
+)
+
+// verifyWrite reads the records of recordName back until they hold value,
+// at most VerifyAttempts times, VerifyInterval seconds apart. GoDaddy may
+// acknowledge a write before serving it, independently of the DNS propagation
+// waitForPropagation checks.
+func verifyWrite(cfg godaddyDNSProviderConfig, client *godaddy.Client, domainZone string, recordName string, value string) error {
+	attempts := cfg.VerifyAttempts
+	if attempts <= 0 {
+		attempts = defaultVerifyAttempts
+	}
+	interval := defaultVerifyInterval
+	if cfg.VerifyInterval > 0 {
+		interval = time.Duration(cfg.VerifyInterval) * time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		records, err := client.Records(domainZone, "TXT", recordName)
+		if err != nil {
+			return err
+		}
+		if client.HasData(records, value) {
+			return nil
+		}
+		if attempt >= attempts || !canRetry(cfg, interval) {
+			return fmt.Errorf("TXT record %q in zone %q not found after %d read-backs", recordName, domainZone, attempt)
+		}
+		if err := sleep(cfg.context(), interval); err != nil {
+			return err
+		}
+	}
+}
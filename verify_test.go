@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snowdrop/godaddy-webhook/internal/godaddy"
+)
+
+func TestPresentVerifyWrite(t *testing.T) {
+	clock, restore := fakeClock()
+	defer restore()
+	start := *clock
+
+	var gets int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+			// GoDaddy never serves the record written
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer cleanup()
+
+	err := c.Present(withConfig(t, ch, `{"verifyWrite": true, "verifyAttempts": 4, "verifyInterval": 5}`))
+	if err == nil || !strings.Contains(err.Error(), "not found after 4 read-backs") {
+		t.Fatalf("expected the verification to fail, got %v", err)
+	}
+	// One read before the write, then the read-backs
+	if gets != 5 {
+		t.Errorf("expected 4 read-backs, got %d", gets-1)
+	}
+	if waited := clock.Sub(start); waited != 15*time.Second {
+		t.Errorf("expected 3 intervals between the read-backs, waited %v", waited)
+	}
+}
+
+func TestPresentVerifyWriteFound(t *testing.T) {
+	gd := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, gd)
+	defer cleanup()
+
+	if err := c.Present(withConfig(t, ch, `{"verifyWrite": true}`)); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+}
+
+func TestPresentVerifyWriteQuoted(t *testing.T) {
+	gd := newFakeGoDaddy(t)
+	c, ch, cleanup := newTestSolver(t, gd)
+	defer cleanup()
+
+	// GoDaddy stored the value with its quotes
+	gd.records["/v1/domains/example.com/records/TXT/_acme-challenge"] = []godaddy.Record{
+		{Type: "TXT", Name: "_acme-challenge", Data: `"` + ch.Key + `"`, TTL: 600},
+	}
+	if err := c.Present(withConfig(t, ch, `{"verifyWrite": true, "ignoreQuotes": true}`)); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+const (
+	// zoneDetectionNS finds zones the way cert-manager does
+	zoneDetectionNS = "ns"
+	// zoneDetectionSOA finds zones by looking for their SOA record
+	zoneDetectionSOA = "soa"
+	// zoneDetectionPublicSuffix derives zones from the public suffix list,
+	// without DNS lookup
+	zoneDetectionPublicSuffix = "publicSuffix"
+)
+
+// dnsQuery is replaced in tests to avoid DNS lookups.
+var dnsQuery = util.DNSQuery
+
+// findZoneBySOA returns the zone of fqdn, i.e. the closest of its parent
+// domains that holds a SOA record, walking up one label at a time. Only SOA
+// records answered for the queried name itself are considered, so that the
+// SOA some split-horizon setups send in the authority section for names they
+// do not own is ignored.
+func findZoneBySOA(fqdn string, nameservers []string) (string, error) {
+	fqdn = util.ToFqdn(fqdn)
+	labels := dns.SplitDomainName(fqdn)
+
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".") + "."
+
+		in, err := dnsQuery(candidate, dns.TypeSOA, nameservers, true)
+		if err != nil {
+			return "", err
+		}
+		if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+			return "", fmt.Errorf("unexpected response code %q for %s", dns.RcodeToString[in.Rcode], candidate)
+		}
+
+		for _, ans := range in.Answer {
+			if soa, ok := ans.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, candidate) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("could not find the SOA record of %s", fqdn)
+}
+
+// zoneByKnownSuffix returns the zone of fqdn when it ends with one of
+// suffixes, i.e. the label preceding the longest matching suffix along with
+// the suffix, e.g. example.internal for _acme-challenge.www.example.internal
+// and the suffix internal. This spares DNS lookups for the custom TLDs that
+// are not resolvable publicly.
+func zoneByKnownSuffix(fqdn string, suffixes []string) (string, bool) {
+	labels := dns.SplitDomainName(util.ToFqdn(fqdn))
+
+	for i := 1; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+		for _, suffix := range suffixes {
+			if strings.EqualFold(util.UnFqdn(strings.TrimPrefix(suffix, ".")), candidate) {
+				return strings.Join(labels[i-1:], ".") + ".", true
+			}
+		}
+	}
+	return "", false
+}
+
+// crossCheckZone looks the zone of fqdn up through each of the first count
+// nameservers separately, and returns an error unless they all find zone.
+// Split-horizon setups may otherwise go unnoticed, e.g. when an internal
+// resolver serves a zone that the public nameservers, and thus the ACME
+// server, see delegated elsewhere.
+func crossCheckZone(fqdn, zone string, nameservers []string, count int) error {
+	if count > len(nameservers) {
+		count = len(nameservers)
+	}
+	if count < 2 {
+		return nil
+	}
+
+	for _, server := range nameservers[:count] {
+		found, err := findZoneBySOA(fqdn, []string{server})
+		if err != nil {
+			return fmt.Errorf("could not cross-check the zone of %s with %s: %v", fqdn, server, err)
+		}
+		if !strings.EqualFold(found, zone) {
+			return fmt.Errorf("the nameservers disagree on the zone of %s: %s found %s instead of %s", fqdn, server, found, zone)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+	"github.com/miekg/dns"
+)
+
+// fakeSOA makes dnsQuery answer with a SOA record for zone only, and returns
+// the names queried.
+func fakeSOA(zone string) (*[]string, func()) {
+	var queried []string
+	dnsQuery = func(fqdn string, rtype uint16, nameservers []string, recursive bool) (*dns.Msg, error) {
+		queried = append(queried, fqdn)
+		in := &dns.Msg{}
+		if fqdn == zone {
+			in.Answer = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA}, Ns: "ns1." + zone}}
+			return in, nil
+		}
+		// Split-horizon servers may answer with the SOA of their own zone
+		in.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: "internal.", Rrtype: dns.TypeSOA}}}
+		return in, nil
+	}
+	return &queried, func() { dnsQuery = util.DNSQuery }
+}
+
+func TestFindZoneBySOA(t *testing.T) {
+	queried, restore := fakeSOA("example.com.")
+	defer restore()
+
+	zone, err := findZoneBySOA("_acme-challenge.sub.example.com", util.RecursiveNameservers)
+	if err != nil {
+		t.Fatalf("findZoneBySOA failed: %v", err)
+	}
+	if zone != "example.com." {
+		t.Errorf("expected zone example.com., got %q", zone)
+	}
+	want := []string{"_acme-challenge.sub.example.com.", "sub.example.com.", "example.com."}
+	if len(*queried) != len(want) {
+		t.Fatalf("expected queries %v, got %v", want, *queried)
+	}
+	for i := range want {
+		if (*queried)[i] != want[i] {
+			t.Errorf("expected queries %v, got %v", want, *queried)
+		}
+	}
+}
+
+func TestFindZoneBySOANotFound(t *testing.T) {
+	_, restore := fakeSOA("example.org.")
+	defer restore()
+
+	if zone, err := findZoneBySOA("_acme-challenge.example.com.", util.RecursiveNameservers); err == nil {
+		t.Errorf("expected no zone to be found, got %q", zone)
+	}
+}
+
+func TestGetZoneStrategy(t *testing.T) {
+	_, restore := fakeSOA("sub.example.com.")
+	defer restore()
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	c := &godaddyDNSSolver{}
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{"", "example.com"},
+		{zoneDetectionNS, "example.com"},
+		{zoneDetectionSOA, "sub.example.com"},
+	}
+	for _, tt := range tests {
+		zone, err := c.getZone(godaddyDNSProviderConfig{ZoneDetection: tt.strategy}, "sub.example.com.")
+		if err != nil {
+			t.Errorf("getZone with strategy %q failed: %v", tt.strategy, err)
+		}
+		if zone != tt.want {
+			t.Errorf("getZone with strategy %q = %q, want %q", tt.strategy, zone, tt.want)
+		}
+	}
+
+	if _, err := c.getZone(godaddyDNSProviderConfig{ZoneDetection: "axfr"}, "sub.example.com."); err == nil {
+		t.Errorf("expected an invalid strategy to be rejected")
+	}
+}
+
+func TestGetZoneKnownSuffixes(t *testing.T) {
+	var lookups []string
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		lookups = append(lookups, fqdn)
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	c := &godaddyDNSSolver{}
+	cfg := godaddyDNSProviderConfig{KnownZoneSuffixes: []string{"internal", ".corp.lan."}}
+	tests := []struct {
+		fqdn string
+		want string
+	}{
+		{"_acme-challenge.www.example.internal.", "example.internal"},
+		{"_acme-challenge.Example.CORP.lan.", "Example.CORP.lan"},
+		{"_acme-challenge.www.example.com.", "example.com"},
+		{"internal.", "example.com"},
+	}
+	for _, tt := range tests {
+		zone, err := c.getZone(cfg, tt.fqdn)
+		if err != nil {
+			t.Errorf("getZone(%q) failed: %v", tt.fqdn, err)
+		}
+		if zone != tt.want {
+			t.Errorf("getZone(%q) = %q, want %q", tt.fqdn, zone, tt.want)
+		}
+	}
+	if len(lookups) != 2 {
+		t.Errorf("expected DNS lookups only for the names without known suffix, got %v", lookups)
+	}
+}
+
+func TestGetZoneCrossCheck(t *testing.T) {
+	// The internal resolver serves sub.example.com, the public ones do not
+	dnsQuery = func(fqdn string, rtype uint16, nameservers []string, recursive bool) (*dns.Msg, error) {
+		zone := "example.com."
+		if nameservers[0] == "10.0.0.53:53" {
+			zone = "sub.example.com."
+		}
+		in := &dns.Msg{}
+		if fqdn == zone {
+			in.Answer = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA}}}
+		}
+		return in, nil
+	}
+	defer func() { dnsQuery = util.DNSQuery }()
+
+	tests := []struct {
+		servers []string
+		count   int
+		wantErr bool
+	}{
+		{[]string{"10.0.0.53:53", "8.8.8.8:53"}, 0, false},
+		{[]string{"10.0.0.53:53", "8.8.8.8:53"}, 1, false},
+		{[]string{"10.0.0.53:53", "8.8.8.8:53"}, 2, true},
+		{[]string{"10.0.0.53:53", "8.8.8.8:53"}, 5, true},
+		{[]string{"1.1.1.1:53", "8.8.8.8:53", "10.0.0.53:53"}, 2, false},
+		{[]string{"1.1.1.1:53", "8.8.8.8:53", "10.0.0.53:53"}, 3, true},
+	}
+	for _, tt := range tests {
+		c := &godaddyDNSSolver{}
+		cfg := godaddyDNSProviderConfig{ZoneDetection: zoneDetectionSOA, Nameservers: tt.servers, ZoneCrossCheck: tt.count}
+		_, err := c.getZone(cfg, "_acme-challenge.sub.example.com.")
+		if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "disagree")) {
+			t.Errorf("%v, %d: expected the nameservers to disagree, got %v", tt.servers, tt.count, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%v, %d: unexpected error %v", tt.servers, tt.count, err)
+		}
+	}
+
+	// The zone cached without cross-check is checked when required
+	c := &godaddyDNSSolver{}
+	cfg := godaddyDNSProviderConfig{ZoneDetection: zoneDetectionSOA, Nameservers: []string{"10.0.0.53:53", "8.8.8.8:53"}}
+	if _, err := c.getZone(cfg, "_acme-challenge.sub.example.com."); err != nil {
+		t.Fatalf("getZone failed: %v", err)
+	}
+	cfg.ZoneCrossCheck = 2
+	if _, err := c.getZone(cfg, "_acme-challenge.sub.example.com."); err == nil || !strings.Contains(err.Error(), "disagree") {
+		t.Errorf("expected the cached zone not to skip the cross-check, got %v", err)
+	}
+}
+
+func TestPresentRecordNameInDetectedZone(t *testing.T) {
+	_, restore := fakeSOA("example.com.")
+	defer restore()
+
+	tests := []struct {
+		name         string
+		config       string
+		fqdn         string
+		resolvedZone string
+		want         string
+	}{
+		{
+			name:         "known suffix",
+			config:       `{"knownZoneSuffixes": ["internal"]}`,
+			fqdn:         "_acme-challenge.www.example.internal.",
+			resolvedZone: "www.example.internal.",
+			want:         "/v1/domains/example.internal/records/TXT/_acme-challenge.www",
+		},
+		{
+			name:         "soa",
+			config:       `{"zoneDetection": "soa"}`,
+			fqdn:         "_acme-challenge.www.example.com.",
+			resolvedZone: "www.example.com.",
+			want:         "/v1/domains/example.com/records/TXT/_acme-challenge.www",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var puts []string
+			c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut {
+					puts = append(puts, r.URL.Path)
+				}
+				w.Write([]byte(`[]`))
+			}))
+			defer cleanup()
+
+			// cert-manager resolved another zone than the one written to
+			ch = withConfig(t, ch, tt.config)
+			ch.ResolvedFQDN = tt.fqdn
+			ch.ResolvedZone = tt.resolvedZone
+			if err := c.Present(ch); err != nil {
+				t.Fatalf("Present failed: %v", err)
+			}
+			if len(puts) != 1 || puts[0] != tt.want {
+				t.Errorf("expected the record to be written to %s, got %v", tt.want, puts)
+			}
+		})
+	}
+}
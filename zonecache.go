@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultZoneCacheTTL is how long the zone found for a name is remembered.
+const defaultZoneCacheTTL = 5 * time.Minute
+
+// zoneCache remembers the zones found through DNS, sparing the recursive
+// nameservers a lookup per challenge. The zero value is ready to use.
+type zoneCache struct {
+	mu      sync.Mutex
+	entries map[string]zoneCacheEntry
+}
+
+type zoneCacheEntry struct {
+	zone    string
+	expires time.Time
+}
+
+// get returns the zone cached for key, if it has not expired.
+func (z *zoneCache) get(key string) (string, bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	e, ok := z.entries[key]
+	if !ok || !now().Before(e.expires) {
+		return "", false
+	}
+	return e.zone, true
+}
+
+// put caches zone for key during ttl, and drops the expired entries.
+func (z *zoneCache) put(key, zone string, ttl time.Duration) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.entries == nil {
+		z.entries = map[string]zoneCacheEntry{}
+	}
+	current := now()
+	for k, e := range z.entries {
+		if !current.Before(e.expires) {
+			delete(z.entries, k)
+		}
+	}
+	z.entries[key] = zoneCacheEntry{zone: zone, expires: current.Add(ttl)}
+}
+
+// zoneCacheTTL returns how long the zones are cached, 0 if they are not.
+func zoneCacheTTL(cfg godaddyDNSProviderConfig) time.Duration {
+	switch {
+	case cfg.ZoneCacheTTL < 0:
+		return 0
+	case cfg.ZoneCacheTTL == 0:
+		return defaultZoneCacheTTL
+	}
+	return time.Duration(cfg.ZoneCacheTTL) * time.Second
+}
+
+// maxLastKnownZones bounds the number of zones lastKnownZones remembers.
+const maxLastKnownZones = 1024
+
+// lastKnownZones remembers the last zone found for each name, keyed like
+// zoneCache, to fall back on when a lookup fails and UseLastKnownZoneOnError is
+// set. They do not expire, but only the latest maxLastKnownZones names are
+// remembered. The zero value is ready to use.
+type lastKnownZones struct {
+	mu    sync.Mutex
+	zones map[string]string
+	order []string
+}
+
+func (l *lastKnownZones) get(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	zone, ok := l.zones[key]
+	return zone, ok
+}
+
+// put remembers zone for key, forgetting the oldest name when full.
+func (l *lastKnownZones) put(key, zone string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.zones == nil {
+		l.zones = map[string]string{}
+	}
+	if _, ok := l.zones[key]; !ok {
+		if len(l.order) >= maxLastKnownZones {
+			delete(l.zones, l.order[0])
+			l.order = l.order[1:]
+		}
+		l.order = append(l.order, key)
+	}
+	l.zones[key] = zone
+}
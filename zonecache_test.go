@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+func TestGetZoneCached(t *testing.T) {
+	current, restore := fakeClock()
+	defer restore()
+
+	var lookups int
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		lookups++
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	tests := []struct {
+		name    string
+		cfg     godaddyDNSProviderConfig
+		elapsed time.Duration
+		lookups int
+	}{
+		{"default within TTL", godaddyDNSProviderConfig{}, 4 * time.Minute, 1},
+		{"default after TTL", godaddyDNSProviderConfig{}, 5 * time.Minute, 2},
+		{"configured within TTL", godaddyDNSProviderConfig{ZoneCacheTTL: 60}, 59 * time.Second, 1},
+		{"configured after TTL", godaddyDNSProviderConfig{ZoneCacheTTL: 60}, time.Minute, 2},
+		{"disabled", godaddyDNSProviderConfig{ZoneCacheTTL: -1}, 0, 2},
+	}
+	for _, tt := range tests {
+		c := &godaddyDNSSolver{}
+		lookups = 0
+		for i := 0; i < 2; i++ {
+			zone, err := c.getZone(tt.cfg, "example.com.")
+			if err != nil {
+				t.Fatalf("%s: getZone failed: %v", tt.name, err)
+			}
+			if zone != "example.com" {
+				t.Errorf("%s: expected zone example.com, got %q", tt.name, zone)
+			}
+			*current = current.Add(tt.elapsed)
+		}
+		if lookups != tt.lookups {
+			t.Errorf("%s: expected %d lookups, got %d", tt.name, tt.lookups, lookups)
+		}
+	}
+}
+
+func TestGetZoneNameservers(t *testing.T) {
+	var queried [][]string
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		queried = append(queried, nameservers)
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	c := &godaddyDNSSolver{}
+	configured := []string{"10.0.0.53:53"}
+	for _, cfg := range []godaddyDNSProviderConfig{{}, {Nameservers: configured}} {
+		if _, err := c.getZone(cfg, "example.com."); err != nil {
+			t.Fatalf("getZone failed: %v", err)
+		}
+	}
+	if len(queried) != 2 {
+		t.Fatalf("expected the configured nameservers not to share the cached zone, got %d lookups", len(queried))
+	}
+	if !reflect.DeepEqual(queried[0], util.RecursiveNameservers) {
+		t.Errorf("expected the recursive nameservers by default, got %v", queried[0])
+	}
+	if !reflect.DeepEqual(queried[1], configured) {
+		t.Errorf("expected the configured nameservers, got %v", queried[1])
+	}
+}
+
+func TestGetZoneLastKnown(t *testing.T) {
+	lookupErr := errors.New("i/o timeout")
+	var failing bool
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		if failing {
+			return "", lookupErr
+		}
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	for _, useLastKnown := range []bool{false, true} {
+		c := &godaddyDNSSolver{}
+		// The cache must not hide the failures
+		cfg := godaddyDNSProviderConfig{ZoneCacheTTL: -1, UseLastKnownZoneOnError: useLastKnown}
+
+		failing = true
+		if _, err := c.getZone(cfg, "_acme-challenge.example.com."); err != lookupErr {
+			t.Errorf("expected the error without last known zone, got %v", err)
+		}
+
+		failing = false
+		if _, err := c.getZone(cfg, "_acme-challenge.example.com."); err != nil {
+			t.Fatalf("getZone failed: %v", err)
+		}
+
+		failing = true
+		zone, err := c.getZone(cfg, "_acme-challenge.Example.com.")
+		switch {
+		case useLastKnown && (err != nil || zone != "example.com"):
+			t.Errorf("expected the last known zone example.com, got %q, %v", zone, err)
+		case !useLastKnown && err != lookupErr:
+			t.Errorf("expected the error unless useLastKnownZoneOnError is set, got %q, %v", zone, err)
+		}
+	}
+}
+
+func TestGetZoneLastKnownPerNameservers(t *testing.T) {
+	lookupErr := errors.New("i/o timeout")
+	var failing bool
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		if failing {
+			return "", lookupErr
+		}
+		return "example.com.", nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	c := &godaddyDNSSolver{}
+	cfg := godaddyDNSProviderConfig{ZoneCacheTTL: -1, UseLastKnownZoneOnError: true, Nameservers: []string{"10.0.0.1:53"}}
+	if _, err := c.getZone(cfg, "_acme-challenge.example.com."); err != nil {
+		t.Fatalf("getZone failed: %v", err)
+	}
+
+	// The zone found through other nameservers is not used
+	failing = true
+	cfg.Nameservers = []string{"10.0.0.2:53"}
+	if zone, err := c.getZone(cfg, "_acme-challenge.example.com."); err != lookupErr {
+		t.Errorf("expected the error without last known zone for these nameservers, got %q, %v", zone, err)
+	}
+}
+
+func TestLastKnownZonesBounded(t *testing.T) {
+	var l lastKnownZones
+	for i := 0; i <= maxLastKnownZones; i++ {
+		l.put(fmt.Sprintf("name-%d", i), "example.com")
+	}
+	// Updating a remembered name does not evict another one
+	l.put("name-1", "example.org")
+
+	if _, ok := l.get("name-0"); ok {
+		t.Errorf("expected the oldest name to be forgotten")
+	}
+	if zone, ok := l.get("name-1"); !ok || zone != "example.org" {
+		t.Errorf("expected the updated zone of name-1, got %q, %v", zone, ok)
+	}
+	if len(l.zones) != maxLastKnownZones || len(l.order) != maxLastKnownZones {
+		t.Errorf("expected %d zones, got %d", maxLastKnownZones, len(l.zones))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "sync"
+
+// zoneLocks serializes the operations on each zone. The lock of a zone is
+// only kept while operations hold or wait for it. The zero value is ready to
+// use.
+type zoneLocks struct {
+	mu    sync.Mutex
+	locks map[string]*zoneLock
+}
+
+// zoneLock is the lock of a zone, along with the number of operations holding
+// or waiting for it.
+type zoneLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock waits until no other operation runs on zone, and returns the function
+// ending the operation.
+func (z *zoneLocks) lock(zone string) func() {
+	z.mu.Lock()
+	if z.locks == nil {
+		z.locks = map[string]*zoneLock{}
+	}
+	l, ok := z.locks[zone]
+	if !ok {
+		l = &zoneLock{}
+		z.locks[zone] = l
+	}
+	l.refs++
+	z.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(z.locks, zone)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/issuer/acme/dns/util"
+)
+
+func TestPresentSerializeZones(t *testing.T) {
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	var maxSameZone, maxZones int
+	c, ch, cleanup := newTestSolver(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zone := strings.Split(r.URL.Path, "/")[3]
+		mu.Lock()
+		inFlight[zone]++
+		if inFlight[zone] > maxSameZone {
+			maxSameZone = inFlight[zone]
+		}
+		if len(inFlight) > maxZones {
+			maxZones = len(inFlight)
+		}
+		mu.Unlock()
+
+		// Leave time for the other operations to overlap
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		if inFlight[zone]--; inFlight[zone] == 0 {
+			delete(inFlight, zone)
+		}
+		mu.Unlock()
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer cleanup()
+	findZoneByFqdn = func(fqdn string, nameservers []string) (string, error) {
+		return fqdn, nil
+	}
+	defer func() { findZoneByFqdn = util.FindZoneByFqdn }()
+
+	ch = withConfig(t, ch, `{"serializeZones": true}`)
+	var wg sync.WaitGroup
+	for _, zone := range []string{"example.com.", "example.com.", "example.com.", "example.org."} {
+		zoneCh := *ch
+		zoneCh.ResolvedZone = zone
+		zoneCh.ResolvedFQDN = "_acme-challenge." + zone
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Present(&zoneCh); err != nil {
+				t.Errorf("Present failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxSameZone != 1 {
+		t.Errorf("expected the operations on a zone not to interleave, got %d at once", maxSameZone)
+	}
+	if maxZones != 2 {
+		t.Errorf("expected the zones to be written in parallel, got %d at once", maxZones)
+	}
+}
+
+func TestZoneLocksReleased(t *testing.T) {
+	var z zoneLocks
+	unlock := z.lock("example.com")
+
+	locked := make(chan struct{})
+	go func() {
+		z.lock("example.com")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("expected the second operation to wait for the first one")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+	if len(z.locks) != 0 {
+		t.Errorf("expected the locks to be dropped once released, got %d", len(z.locks))
+	}
+}